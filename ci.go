@@ -0,0 +1,99 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CI providers supported by WithCIGroupedOutput
+const (
+	CIProviderNone          = ""
+	CIProviderGitHubActions = "github"
+	CIProviderGitLab        = "gitlab"
+	CIProviderBuildkite     = "buildkite"
+)
+
+// Detect the CI provider from environment variables, return CIProviderNone
+// when not running in a supported CI
+func DetectCIProvider() string {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return CIProviderGitHubActions
+	case os.Getenv("GITLAB_CI") == "true":
+		return CIProviderGitLab
+	case os.Getenv("BUILDKITE") == "true":
+		return CIProviderBuildkite
+	}
+	return CIProviderNone
+}
+
+// escape data of a github workflow command
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escape a property value of a github workflow command
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// return the full output of a job wrapped in collapsible section markers for
+// the given provider, failed jobs are expanded (when supported) and annotated
+func (e *JobExecutor) ciGroupString(provider string, j *job) string {
+	title := trim(e.execTemplate("jobStatusLine", j))
+	body := e.execTemplate("jobStatusFull", j)
+	j.mutex.RLock()
+	err, start, end, id := j.Err, j.StartTime, j.EndTime, j.id
+	j.mutex.RUnlock()
+	var sb strings.Builder
+	switch provider {
+	case CIProviderGitHubActions:
+		fmt.Fprintf(&sb, "::group::%s\n%s::endgroup::\n", escapeGitHubData(title), body)
+		if err != nil {
+			fmt.Fprintf(&sb, "::error title=%s::%s\n", escapeGitHubProperty(j.Name()), escapeGitHubData(err.Error()))
+		}
+	case CIProviderGitLab:
+		// section names only accept [a-zA-Z0-9_.-]
+		name := fmt.Sprintf("job_%d", id)
+		options := "[collapsed=true]"
+		if err != nil {
+			options = ""
+		}
+		fmt.Fprintf(&sb, "\033[0Ksection_start:%d:%s%s\r\033[0K%s\n%s", start.Unix(), name, options, title, body)
+		fmt.Fprintf(&sb, "\033[0Ksection_end:%d:%s\r\033[0K\n", end.Unix(), name)
+	case CIProviderBuildkite:
+		marker := "---"
+		if err != nil {
+			marker = "+++" // expanded
+		}
+		fmt.Fprintf(&sb, "%s %s\n%s", marker, title, body)
+	default:
+		sb.WriteString(body)
+	}
+	return sb.String()
+}
+
+// Display the full status of each job as it terminates, wrapped in collapsible
+// sections for the CI provider detected from the environment (see
+// DetectCIProvider): GitHub Actions groups, GitLab sections or Buildkite
+// groups. Failed jobs are expanded and annotated with ::error on GitHub Actions.
+// When not running in a supported CI it behaves like WithFifoOutput.
+func (e *JobExecutor) WithCIGroupedOutput() *JobExecutor {
+	return e.WithCIGroupedOutputFor(DetectCIProvider())
+}
+
+// Same as WithCIGroupedOutput for the given CI provider (one of CIProvider* constants)
+func (e *JobExecutor) WithCIGroupedOutputFor(provider string) *JobExecutor {
+	e.onJobDone(func(jobs JobList, jobId int) {
+		fmt.Fprint(e.getOutput(), e.ciGroupString(provider, jobs[jobId]))
+	})
+	return e
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetectCIProvider(t *testing.T) {
+	for _, name := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE"} {
+		t.Setenv(name, "")
+	}
+	if got := DetectCIProvider(); got != CIProviderNone {
+		t.Errorf("DetectCIProvider() = %q, want none", got)
+	}
+	t.Setenv("BUILDKITE", "true")
+	if got := DetectCIProvider(); got != CIProviderBuildkite {
+		t.Errorf("DetectCIProvider() = %q, want %q", got, CIProviderBuildkite)
+	}
+	t.Setenv("GITLAB_CI", "true")
+	if got := DetectCIProvider(); got != CIProviderGitLab {
+		t.Errorf("DetectCIProvider() = %q, want %q", got, CIProviderGitLab)
+	}
+	t.Setenv("GITHUB_ACTIONS", "true")
+	if got := DetectCIProvider(); got != CIProviderGitHubActions {
+		t.Errorf("DetectCIProvider() = %q, want %q", got, CIProviderGitHubActions)
+	}
+}
+
+func TestJobExecutor_WithCIGroupedOutputFor(t *testing.T) {
+	failFn := func() (string, error) { return "some\noutput", errors.New("bad: 100%\nfailure") }
+	tests := []struct {
+		provider string
+		contains []string
+	}{
+		{CIProviderGitHubActions, []string{
+			"::group::👍 Success  success\n👍 Success  success:\n  done\n::endgroup::\n",
+			"::group::💥 Error    fail\n",
+			"::endgroup::\n::error title=fail::bad: 100%25%0Afailure\n",
+		}},
+		{CIProviderGitLab, []string{
+			"section_start:",
+			":job_0[collapsed=true]\r\033[0K👍 Success  success\n",
+			":job_1\r\033[0K💥 Error    fail\n",
+			"\033[0Ksection_end:",
+		}},
+		{CIProviderBuildkite, []string{
+			"--- 👍 Success  success\n👍 Success  success:\n  done\n",
+			"+++ 💥 Error    fail\n",
+		}},
+		{CIProviderNone, []string{"👍 Success  success:\n  done\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			var out bytes.Buffer
+			NewExecutor().SetOutput(&out).
+				AddNamedJobFn("success", TestRunnableSuccessFn).
+				AddNamedJobFn("fail", failFn).
+				WithCIGroupedOutputFor(tt.provider).
+				DagExecute()
+			for _, want := range tt.contains {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output should contain %q, got:\n%q", want, out.String())
+				}
+			}
+		})
+	}
+}
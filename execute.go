@@ -9,7 +9,6 @@ package jobExecutor
 import (
 	"runtime"
 	"sync"
-	"time"
 )
 
 // never close this channel, it's only purpose is to limit concurrency.
@@ -33,6 +32,87 @@ type executeOptions struct {
 	onJobStart  func(jobs JobList, jobIndex int)
 	onJobDone   func(jobs JobList, jobIndex int)
 	onJobsDone  func(jobs JobList)
+	onJobOutput func(jobs JobList, jobIndex int, line string)
+	// limit concurrency of this executor only, nil means use limiterChan
+	limiter chan struct{}
+	// limiters of the parent run when running as a sub executor
+	parentLimiters limiters
+}
+
+// a job must get a slot in each limiter to run, they are always acquired in
+// the same order (innermost first) so nested runs can't deadlock
+type limiters []chan struct{}
+
+func (l limiters) acquire() {
+	for _, c := range l {
+		c <- struct{}{}
+	}
+}
+
+func (l limiters) release() {
+	for i := len(l) - 1; i >= 0; i-- {
+		<-l[i]
+	}
+}
+
+// return the limiters to use for a run: the executor one (default to
+// limiterChan) or the parent ones for sub executors, in which case the
+// executor limiter, if any, is an additional bound
+func (opts executeOptions) getLimiters() limiters {
+	switch {
+	case opts.parentLimiters != nil && opts.limiter != nil:
+		return append(limiters{opts.limiter}, opts.parentLimiters...)
+	case opts.parentLimiters != nil:
+		return opts.parentLimiters
+	case opts.limiter != nil:
+		return limiters{opts.limiter}
+	}
+	return limiters{limiterChan}
+}
+
+// return the number of jobs that can run at once with these limiters
+func (l limiters) capacity() int {
+	res := 0
+	for i, c := range l {
+		if i == 0 || cap(c) < res {
+			res = cap(c)
+		}
+	}
+	return res
+}
+
+// keep track of concurrency slots used during a run: a slot is the index of a
+// running job among the concurrent ones, the lowest free slot is always used
+type slotPool struct {
+	mutex sync.Mutex
+	used  []bool
+}
+
+func (p *slotPool) acquire() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, used := range p.used {
+		if !used {
+			p.used[i] = true
+			return i
+		}
+	}
+	p.used = append(p.used, true)
+	return len(p.used) - 1
+}
+
+func (p *slotPool) release(slot int) {
+	p.mutex.Lock()
+	p.used[slot] = false
+	p.mutex.Unlock()
+}
+
+// return the output handler to pass to job.run or nil if none is set
+func getJobOutputHandler(jobs JobList, jobIndex int, opts executeOptions) func(line string) {
+	if opts.onJobOutput == nil {
+		return nil
+	}
+	return func(line string) { opts.onJobOutput(jobs, jobIndex, line) }
 }
 
 // effectively launch the child process, call on jobDone
@@ -41,24 +121,37 @@ type executeOptions struct {
 // returns the number of errors encountered
 // @todo add cancelation support
 func execute(jobs JobList, opts executeOptions) {
+	for _, job := range jobs {
+		job.setEnqueued()
+	}
 	if opts.onJobsStart != nil {
 		opts.onJobsStart(jobs)
 	}
+	// keep a reference to the limiter in case SetMaxConcurrentJobs is called meanwhile
+	limiter := opts.getLimiters()
 	var wg sync.WaitGroup
+	var slots slotPool
 	wg.Add(len(jobs))
+	for _, job := range jobs {
+		job.setReady()
+	}
 	for i, child := range jobs {
-		limiterChan <- struct{}{}
+		limiter.acquire()
 		jobIndex := i
 		job := child
-		job.mutex.Lock()
-		job.StartTime = time.Now()
-		job.status = JobStateRunning
-		job.mutex.Unlock()
-		if opts.onJobStart != nil {
+		if job.sub != nil {
+			job.parentLimiters = limiter
+		}
+		slot := slots.acquire()
+		// onJobStart is called one job at a time and not for skipped jobs
+		if job.start(slot) && opts.onJobStart != nil {
 			opts.onJobStart(jobs, jobIndex)
 		}
-		go job.run(func() {
-			defer func() { <-limiterChan }()
+		go job.run(getJobOutputHandler(jobs, jobIndex, opts), func() {
+			defer func() {
+				slots.release(slot)
+				limiter.release()
+			}()
 			defer wg.Done()
 			if opts.onJobDone != nil {
 				opts.onJobDone(jobs, jobIndex)
@@ -74,50 +167,75 @@ func execute(jobs JobList, opts executeOptions) {
 
 // cyclic dependency check MUST be done before calling this function if not it may wait forever
 func dagExecute(jobs JobList, opts executeOptions) error {
+	ids := make([]int, len(jobs))
+	for i := range jobs {
+		ids[i] = i
+	}
+	return dagExecuteSubset(jobs, ids, opts)
+}
 
+// same as dagExecute but only run jobs with given ids, dependencies on jobs
+// outside of the subset are considered already resolved and won't be run.
+// events handlers still receive the whole JobList
+func dagExecuteSubset(jobs JobList, ids []int, opts executeOptions) error {
+	for _, id := range ids {
+		jobs[id].setEnqueued()
+	}
 	if opts.onJobsStart != nil {
 		opts.onJobsStart(jobs)
 	}
-	length := len(jobs)
+	length := len(ids)
+	inSubset := make(map[int]bool, length)
+	for _, id := range ids {
+		inSubset[id] = true
+	}
 	// create a list of edges
 	adjacencyList := make(map[int][]int, length)
 	// count dependent
 	dependentCount := make(map[int]int, length)
-	for _, job := range jobs {
-		for _, to := range job.DependsOn {
-			adjacencyList[to.id] = append(adjacencyList[to.id], job.id)
-			dependentCount[job.id]++
+	for _, id := range ids {
+		for _, to := range jobs[id].DependsOn {
+			if !inSubset[to.id] {
+				continue
+			}
+			adjacencyList[to.id] = append(adjacencyList[to.id], id)
+			dependentCount[id]++
 		}
 	}
 	// init a queue with starter jobs
 	var jobQueue []int
-	for id := range jobs {
+	for _, id := range ids {
 		if dependentCount[id] == 0 {
+			jobs[id].setReady()
 			jobQueue = append(jobQueue, id)
 		}
 	}
 
+	// keep a reference to the limiter in case SetMaxConcurrentJobs is called meanwhile
+	limiter := opts.getLimiters()
 	var wg sync.WaitGroup
+	var slots slotPool
 	wg.Add(length)
 	doneChan := make(chan int)
 	defer func() { close(doneChan) }()
 	doneJob := 0
-	for doneJob < len(jobs) { // until all jobs are done
+	for doneJob < length { // until all jobs are done
 		for len(jobQueue) > 0 { // while the queue is not empty
 			job := jobs[jobQueue[0]] // unqueue job
 			jobQueue = jobQueue[1:]
-			limiterChan <- struct{}{} // Wait if we are over the concurrency limit
+			limiter.acquire() // Wait if we are over the concurrency limit
+			if job.sub != nil {
+				job.parentLimiters = limiter
+			}
 			// run job
-			job.mutex.Lock()
-			job.StartTime = time.Now()
-			job.status = JobStateRunning
-			job.mutex.Unlock()
-			if opts.onJobStart != nil {
+			slot := slots.acquire()
+			if job.start(slot) && opts.onJobStart != nil {
 				opts.onJobStart(jobs, job.id)
 			}
-			go job.run(func() {
+			go job.run(getJobOutputHandler(jobs, job.id, opts), func() {
 				defer func() {
-					<-limiterChan
+					slots.release(slot)
+					limiter.release()
 					doneChan <- job.id
 				}()
 				defer wg.Done()
@@ -132,6 +250,7 @@ func dagExecute(jobs JobList, opts executeOptions) error {
 			for _, to := range adjacencyList[doneId] {
 				dependentCount[to]--
 				if dependentCount[to] == 0 {
+					jobs[to].setReady()
 					jobQueue = append(jobQueue, to)
 				}
 			}
@@ -1,3 +1,10 @@
 module github.com/software-t-rex/go-jobExecutor/v2
 
-go 1.20
+go 1.21
+
+require (
+	golang.org/x/term v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.28.0 // indirect
@@ -0,0 +1,271 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// colors used for each job state when GraphOptions.ShowState is set
+var graphStateColors = map[string]string{
+	"succeed":  "#2e7d32",
+	"failed":   "#c62828",
+	"skipped":  "#616161",
+	"canceled": "#ef6c00",
+	"running":  "#1565c0",
+	"pending":  "#424242",
+}
+
+// Options for graph exports
+type GraphOptions struct {
+	// direction of the graph: "RL" (default), "LR", "TB" or "BT"
+	RankDir string
+	// color nodes by their current or final state (succeed, failed, skipped,
+	// canceled, running, pending) and add their duration to labels
+	ShowState bool
+	// override the default color of some states, ie: {"failed": "#ff0000"}
+	StateColors map[string]string
+	// dot only: graph background, label and edge colors (default to a dark theme)
+	BgColor   string
+	FontColor string
+	EdgeColor string
+}
+
+func (o GraphOptions) rankDir() string {
+	switch o.RankDir {
+	case "LR", "TB", "BT":
+		return o.RankDir
+	}
+	return "RL"
+}
+
+func (o GraphOptions) stateColor(state string) string {
+	if color, ok := o.StateColors[state]; ok {
+		return color
+	}
+	return graphStateColors[state]
+}
+
+// return the node label with its duration when ShowState is set
+func (o GraphOptions) label(n graphNode) string {
+	if o.ShowState && n.Outcome != "pending" && n.Outcome != "skipped" {
+		return n.Name + "\n" + formatDuration(n.Duration)
+	}
+	return n.Name
+}
+
+type graphNode struct {
+	Id       int
+	Name     string
+	Outcome  string // see nodeState
+	Duration time.Duration
+	// length of the longest chain of dependencies leading to this job
+	Level int
+}
+
+// an edge from a job to one of its dependencies
+type graphEdge struct {
+	From int
+	To   int
+}
+
+// same as jobOutcome but distinguish running jobs from pending ones
+func nodeState(j *job) string {
+	outcome := jobOutcome(j)
+	if outcome == "pending" && j.IsState(JobStateRunning) {
+		return "running"
+	}
+	return outcome
+}
+
+// return nodes and edges of the jobs dependency graph
+func (e *JobExecutor) getGraph() ([]graphNode, []graphEdge) {
+	nodes := make([]graphNode, len(e.jobs))
+	var edges []graphEdge
+	for i, j := range e.jobs {
+		j.mutex.RLock()
+		nodes[i] = graphNode{Id: j.id, Name: j.Name(), Duration: j.Duration}
+		for _, dep := range j.DependsOn {
+			edges = append(edges, graphEdge{From: j.id, To: dep.id})
+		}
+		j.mutex.RUnlock()
+		nodes[i].Outcome = nodeState(j)
+	}
+	levels := make(map[int]int, len(e.jobs))
+	visiting := make(map[int]bool)
+	var getLevel func(j *job) int
+	getLevel = func(j *job) int {
+		if level, ok := levels[j.id]; ok {
+			return level
+		}
+		if visiting[j.id] { // cyclic dependency
+			return 0
+		}
+		visiting[j.id] = true
+		level := 0
+		for _, dep := range j.DependsOn {
+			if l := getLevel(dep) + 1; l > level {
+				level = l
+			}
+		}
+		visiting[j.id] = false
+		levels[j.id] = level
+		return level
+	}
+	for i, j := range e.jobs {
+		nodes[i].Level = getLevel(j)
+	}
+	return nodes, edges
+}
+
+// return a graphviz dot representation of the execution graph with the given options
+func (e *JobExecutor) GetDotWithOptions(opts GraphOptions) string {
+	bgColor, fontColor, edgeColor := opts.BgColor, opts.FontColor, opts.EdgeColor
+	if bgColor == "" {
+		bgColor = "#121212"
+	}
+	if fontColor == "" {
+		fontColor = "black"
+	}
+	if edgeColor == "" {
+		edgeColor = "#f0f0f0"
+	}
+	out := []string{fmt.Sprintf(`digraph G{
+	graph [bgcolor="%s" fontcolor="%s" rankdir="%s"]
+	node [colorscheme="set312" style="filled,rounded" shape="box"]
+	edge [color="%s"]`, bgColor, fontColor, opts.rankDir(), edgeColor)}
+	nodes, edges := e.getGraph()
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, n := range nodes {
+		if opts.ShowState {
+			out = append(out, fmt.Sprintf("\t%d [label=\"%s\" color=\"%s\" fontcolor=\"white\"]", n.Id, escaper.Replace(opts.label(n)), opts.stateColor(n.Outcome)))
+		} else {
+			out = append(out, fmt.Sprintf("\t%d [label=\"%s\" color=\"%d\"]", n.Id, escaper.Replace(n.Name), n.Id%12+1))
+		}
+	}
+	hasDeps := make(map[int]bool)
+	for _, edge := range edges {
+		out = append(out, fmt.Sprintf("\t%d -> %d", edge.From, edge.To))
+		hasDeps[edge.From] = true
+	}
+	// finally group all nodes without dependencies
+	noDepNodes := []string{}
+	for _, n := range nodes {
+		if !hasDeps[n.Id] {
+			noDepNodes = append(noDepNodes, fmt.Sprintf("%d", n.Id))
+		}
+	}
+	if len(noDepNodes) > 1 {
+		out = append(out, fmt.Sprintf("\t{rank=same; %s}", strings.Join(noDepNodes, ";")))
+	}
+
+	return strings.Join(out, "\n") + "\n}"
+}
+
+// return a mermaid flowchart representation of the execution graph
+func (e *JobExecutor) GetMermaid(opts GraphOptions) string {
+	out := []string{"flowchart " + opts.rankDir()}
+	nodes, edges := e.getGraph()
+	escaper := strings.NewReplacer(`"`, "#quot;", "\n", "<br>")
+	states := map[string]bool{}
+	for _, n := range nodes {
+		out = append(out, fmt.Sprintf("\tjob%d[\"%s\"]", n.Id, escaper.Replace(opts.label(n))))
+	}
+	for _, edge := range edges {
+		out = append(out, fmt.Sprintf("\tjob%d --> job%d", edge.From, edge.To))
+	}
+	if opts.ShowState {
+		for _, n := range nodes {
+			out = append(out, fmt.Sprintf("\tclass job%d %s", n.Id, n.Outcome))
+			states[n.Outcome] = true
+		}
+		for _, state := range []string{"succeed", "failed", "skipped", "canceled", "running", "pending"} {
+			if states[state] {
+				out = append(out, fmt.Sprintf("\tclassDef %s fill:%s,color:#fff", state, opts.stateColor(state)))
+			}
+		}
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// return a PlantUML representation of the execution graph
+func (e *JobExecutor) GetPlantUML(opts GraphOptions) string {
+	out := []string{"@startuml"}
+	switch opts.rankDir() {
+	case "LR", "RL":
+		out = append(out, "left to right direction")
+	default:
+		out = append(out, "top to bottom direction")
+	}
+	nodes, edges := e.getGraph()
+	escaper := strings.NewReplacer(`"`, `\"`, "\n", `\n`)
+	for _, n := range nodes {
+		node := fmt.Sprintf("rectangle \"%s\" as job%d", escaper.Replace(opts.label(n)), n.Id)
+		if opts.ShowState {
+			node += " " + opts.stateColor(n.Outcome)
+		}
+		out = append(out, node)
+	}
+	for _, edge := range edges {
+		if opts.rankDir() == "RL" || opts.rankDir() == "BT" {
+			out = append(out, fmt.Sprintf("job%d <-- job%d", edge.To, edge.From))
+		} else {
+			out = append(out, fmt.Sprintf("job%d --> job%d", edge.From, edge.To))
+		}
+	}
+	return strings.Join(append(out, "@enduml"), "\n") + "\n"
+}
+
+// return a D2 representation of the execution graph
+func (e *JobExecutor) GetD2(opts GraphOptions) string {
+	directions := map[string]string{"RL": "left", "LR": "right", "TB": "down", "BT": "up"}
+	out := []string{"direction: " + directions[opts.rankDir()]}
+	nodes, edges := e.getGraph()
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, n := range nodes {
+		node := fmt.Sprintf("job%d: \"%s\"", n.Id, escaper.Replace(opts.label(n)))
+		if opts.ShowState {
+			node += fmt.Sprintf(" {style.fill: \"%s\"; style.font-color: \"#ffffff\"}", opts.stateColor(n.Outcome))
+		}
+		out = append(out, node)
+	}
+	for _, edge := range edges {
+		out = append(out, fmt.Sprintf("job%d -> job%d", edge.From, edge.To))
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+type jsonGraphNode struct {
+	Id        int     `json:"id"`
+	Name      string  `json:"name"`
+	State     string  `json:"state"`
+	Duration  float64 `json:"duration"` // seconds
+	DependsOn []int   `json:"dependsOn"`
+}
+
+// Write the execution graph as a JSON adjacency list: a list of nodes with
+// their id, name, state, duration in seconds and the ids of their dependencies
+func (e *JobExecutor) WriteJSONGraph(w io.Writer) error {
+	nodes, edges := e.getGraph()
+	jsonNodes := make([]jsonGraphNode, len(nodes))
+	for i, n := range nodes {
+		jsonNodes[i] = jsonGraphNode{Id: n.Id, Name: n.Name, State: n.Outcome, Duration: n.Duration.Seconds(), DependsOn: []int{}}
+	}
+	for _, edge := range edges {
+		jsonNodes[edge.From].DependsOn = append(jsonNodes[edge.From].DependsOn, edge.To)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Nodes []jsonGraphNode `json:"nodes"`
+	}{jsonNodes})
+}
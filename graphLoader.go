@@ -0,0 +1,254 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidJobName = fmt.Errorf("invalid job name")
+var ErrDuplicateJobName = fmt.Errorf("duplicate job name")
+var ErrUnknownJob = fmt.Errorf("unknown job")
+var ErrInvalidGraphDescription = fmt.Errorf("invalid graph description")
+
+// JobDescription describes a job to add to an executor, Cmd and Run are
+// mutually exclusive, a job without any of them does nothing and can be used
+// to group dependencies.
+type JobDescription struct {
+	// unique name of the job
+	Name string `json:"name" yaml:"name"`
+	// command and its arguments
+	Cmd []string `json:"cmd,omitempty" yaml:"cmd,omitempty"`
+	// script to run with "sh -c"
+	Run string `json:"run,omitempty" yaml:"run,omitempty"`
+	// names of the jobs this job depends on
+	DependsOn []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	// tags of the job, see JobExecutor.JobsWithTag
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// GraphDescription is an adjacency list of jobs, see AddJobsFromGraph
+type GraphDescription struct {
+	Jobs []JobDescription `json:"jobs" yaml:"jobs"`
+}
+
+// check a job name can be used to refer to a job
+func validateJobName(name string) error {
+	if name == "" || strings.TrimSpace(name) != name || strings.ContainsAny(name, "\n\r\t") {
+		return fmt.Errorf("%w: %q", ErrInvalidJobName, name)
+	}
+	return nil
+}
+
+// return the path of a cycle in the dependencies of the given jobs or nil
+func findDependencyCycle(names []string, deps map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(names))
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			switch state[dep] {
+			case visiting:
+				for i, n := range path {
+					if n == dep {
+						return append(append([]string{}, path[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Add the jobs of the given graph description and their dependencies.
+// Names must be unique (including jobs already added to the executor) and
+// dependencies must refer to jobs of the description. Nothing is added if the
+// description is invalid or contains cyclic dependencies.
+func (e *JobExecutor) AddJobsFromGraph(graph GraphDescription) error {
+	names := make([]string, 0, len(graph.Jobs))
+	known := make(map[string]bool, len(e.jobs)+len(graph.Jobs))
+	deps := make(map[string][]string, len(graph.Jobs))
+	for _, j := range e.jobs {
+		known[j.Name()] = true
+	}
+	for _, desc := range graph.Jobs {
+		if err := validateJobName(desc.Name); err != nil {
+			return err
+		}
+		if known[desc.Name] {
+			return fmt.Errorf("%w: %q", ErrDuplicateJobName, desc.Name)
+		}
+		if len(desc.Cmd) > 0 && desc.Run != "" {
+			return fmt.Errorf("%w: job %q can't have both cmd and run", ErrInvalidGraphDescription, desc.Name)
+		}
+		known[desc.Name] = true
+		names = append(names, desc.Name)
+		deps[desc.Name] = desc.DependsOn
+	}
+	for _, desc := range graph.Jobs {
+		for _, dep := range desc.DependsOn {
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("%w: %q required by %q", ErrUnknownJob, dep, desc.Name)
+			}
+		}
+	}
+	if cycle := findDependencyCycle(names, deps); cycle != nil {
+		return fmt.Errorf("%w: %s", ErrCyclicDependencyDetected, strings.Join(cycle, " -> "))
+	}
+	added := make(map[string]Job, len(graph.Jobs))
+	for _, desc := range graph.Jobs {
+		var j interface{}
+		switch {
+		case len(desc.Cmd) > 0:
+			j = exec.Command(desc.Cmd[0], desc.Cmd[1:]...)
+		case desc.Run != "":
+			j = exec.Command("sh", "-c", desc.Run)
+		default:
+			j = func() (string, error) { return "", nil }
+		}
+		added[desc.Name] = e.AddJob(NamedJob{Name: desc.Name, Job: j})
+		e.AddJobTags(added[desc.Name], desc.Tags...)
+	}
+	for _, desc := range graph.Jobs {
+		for _, dep := range desc.DependsOn {
+			e.AddJobDependency(added[desc.Name], added[dep])
+		}
+	}
+	return nil
+}
+
+// Same as AddJobsFromGraph with a JSON description:
+//
+//	{"jobs": [
+//		{"name": "build", "cmd": ["go", "build", "./..."]},
+//		{"name": "test", "run": "go test ./... | tee test.log", "dependsOn": ["build"]}
+//	]}
+func (e *JobExecutor) AddJobsFromJSON(r io.Reader) error {
+	var graph GraphDescription
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&graph); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidGraphDescription, err)
+	}
+	return e.AddJobsFromGraph(graph)
+}
+
+// Same as AddJobsFromGraph with a YAML description:
+//
+//	jobs:
+//	  - name: build
+//	    cmd: [go, build, ./...]
+//	  - name: test
+//	    run: go test ./... | tee test.log
+//	    dependsOn: [build]
+func (e *JobExecutor) AddJobsFromYAML(r io.Reader) error {
+	var graph GraphDescription
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&graph); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidGraphDescription, err)
+	}
+	return e.AddJobsFromGraph(graph)
+}
+
+// Same as AddJobsFromGraph with a Makefile like syntax: each target is a job
+// followed by the names of the jobs it depends on, its recipe lines are
+// indented and run in a single "sh -c" which stops at the first failing line
+// like make does. Targets starting with a dot (like .PHONY) are ignored and
+// lines starting with # are comments.
+//
+//	build:
+//		go build ./...
+//	test: build
+//		go test ./...
+func (e *JobExecutor) AddJobsFromMakefile(r io.Reader) error {
+	var graph GraphDescription
+	var current *JobDescription
+	var recipe []string
+	flush := func() {
+		if current != nil {
+			if len(recipe) > 0 {
+				current.Run = "set -e\n" + strings.Join(recipe, "\n")
+			}
+			graph.Jobs = append(graph.Jobs, *current)
+		}
+		current, recipe = nil, nil
+	}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	var line string
+	for scanner.Scan() {
+		lineNum++
+		line += scanner.Text()
+		if strings.HasSuffix(line, "\\") { // line continuation
+			line = strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		rawLine := line
+		line = ""
+		trimmed := strings.TrimSpace(rawLine)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if rawLine[0] == '\t' || rawLine[0] == ' ' {
+			if current == nil {
+				return fmt.Errorf("%w: line %d: recipe line outside of a target", ErrInvalidGraphDescription, lineNum)
+			}
+			recipe = append(recipe, strings.TrimPrefix(trimmed, "@"))
+			continue
+		}
+		flush()
+		target, depList, found := strings.Cut(trimmed, ":")
+		if !found {
+			return fmt.Errorf("%w: line %d: expected target: dependencies", ErrInvalidGraphDescription, lineNum)
+		}
+		// special targets are kept until the end to also skip their recipes
+		current = &JobDescription{Name: strings.TrimSpace(target), DependsOn: strings.Fields(depList)}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	flush()
+	// remove special targets
+	jobs := graph.Jobs[:0]
+	for _, j := range graph.Jobs {
+		if !strings.HasPrefix(j.Name, ".") {
+			jobs = append(jobs, j)
+		}
+	}
+	graph.Jobs = jobs
+	return e.AddJobsFromGraph(graph)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// return name: dependency names for each job of the executor
+func describeDeps(e *JobExecutor) []string {
+	var res []string
+	for _, j := range e.jobs {
+		var deps []string
+		for _, dep := range j.DependsOn {
+			deps = append(deps, dep.Name())
+		}
+		res = append(res, j.Name()+": "+strings.Join(deps, " "))
+	}
+	return res
+}
+
+func TestJobExecutor_AddJobsFromGraph(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   GraphDescription
+		wantErr error
+		errMsg  string
+	}{
+		{"empty name", GraphDescription{Jobs: []JobDescription{{Name: ""}}}, ErrInvalidJobName, ""},
+		{"name with spaces around", GraphDescription{Jobs: []JobDescription{{Name: " a"}}}, ErrInvalidJobName, ""},
+		{"duplicate name", GraphDescription{Jobs: []JobDescription{{Name: "a"}, {Name: "a"}}}, ErrDuplicateJobName, ""},
+		{"name of an existing job", GraphDescription{Jobs: []JobDescription{{Name: "existing"}}}, ErrDuplicateJobName, ""},
+		{"unknown dependency", GraphDescription{Jobs: []JobDescription{{Name: "a", DependsOn: []string{"b"}}}}, ErrUnknownJob, `"b" required by "a"`},
+		{"cmd and run", GraphDescription{Jobs: []JobDescription{{Name: "a", Cmd: []string{"ls"}, Run: "ls"}}}, ErrInvalidGraphDescription, ""},
+		{"cycle", GraphDescription{Jobs: []JobDescription{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c", DependsOn: []string{"a"}},
+		}}, ErrCyclicDependencyDetected, "a -> b -> c -> a"},
+		{"self dependency", GraphDescription{Jobs: []JobDescription{{Name: "a", DependsOn: []string{"a"}}}}, ErrCyclicDependencyDetected, "a -> a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExecutor().AddNamedJobFn("existing", TestRunnableSuccessFn)
+			err := e.AddJobsFromGraph(tt.graph)
+			if !errors.Is(err, tt.wantErr) || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("AddJobsFromGraph() error = %v, want %v containing %q", err, tt.wantErr, tt.errMsg)
+			}
+			if e.Len() != 1 {
+				t.Errorf("no job should be added on error")
+			}
+		})
+	}
+}
+
+func TestJobExecutor_AddJobsFromJSON(t *testing.T) {
+	e := NewExecutor()
+	err := e.AddJobsFromJSON(strings.NewReader(`{"jobs": [
+		{"name": "test", "run": "echo $((1+1))", "dependsOn": ["build", "lint"]},
+		{"name": "build", "cmd": ["echo", "built"]},
+		{"name": "lint"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"test: build lint", "build: ", "lint: "}; !reflect.DeepEqual(describeDeps(e), want) {
+		t.Errorf("unexpected jobs %v", describeDeps(e))
+	}
+	if errs := e.DagExecute(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if e.jobs[0].Res != "2\n" || e.jobs[1].Res != "built\n" {
+		t.Errorf("unexpected outputs %q %q", e.jobs[0].Res, e.jobs[1].Res)
+	}
+	if err := NewExecutor().AddJobsFromJSON(strings.NewReader(`{"jobs": [{"name": "a", "deps": ["b"]}]}`)); !errors.Is(err, ErrInvalidGraphDescription) {
+		t.Errorf("unknown fields should be rejected, got %v", err)
+	}
+}
+
+func TestJobExecutor_AddJobsFromYAML(t *testing.T) {
+	e := NewExecutor()
+	err := e.AddJobsFromYAML(strings.NewReader(`
+jobs:
+  - name: build
+    cmd: [echo, built]
+  - name: test
+    run: echo tested
+    dependsOn: [build]
+    tags: [ci]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"build: ", "test: build"}; !reflect.DeepEqual(describeDeps(e), want) {
+		t.Errorf("unexpected jobs %v", describeDeps(e))
+	}
+	if jobs := e.JobsWithTag("ci"); len(jobs) != 1 || jobs[0].Name() != "test" {
+		t.Errorf("tags should be loaded, got %v", jobs)
+	}
+	if err := NewExecutor().AddJobsFromYAML(strings.NewReader("jobs: [{name: a, dependsOn: [a]}]")); !errors.Is(err, ErrCyclicDependencyDetected) {
+		t.Errorf("cycle should be detected, got %v", err)
+	}
+}
+
+func TestJobExecutor_AddJobsFromMakefile(t *testing.T) {
+	e := NewExecutor()
+	err := e.AddJobsFromMakefile(strings.NewReader(`# a comment
+.PHONY: all build test
+
+all: build test
+
+build:
+	@echo building
+	echo \
+		built
+
+test: build
+	echo tested
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"all: build test", "build: ", "test: build"}; !reflect.DeepEqual(describeDeps(e), want) {
+		t.Errorf("unexpected jobs %v", describeDeps(e))
+	}
+	if errs := e.DagExecute(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if e.jobs[1].Res != "building\nbuilt\n" {
+		t.Errorf("unexpected build output %q", e.jobs[1].Res)
+	}
+
+	e = NewExecutor()
+	if err := e.AddJobsFromMakefile(strings.NewReader("fail:\n\tfalse\n\techo not reached\n")); err != nil {
+		t.Fatal(err)
+	}
+	if errs := e.DagExecute(); len(errs) != 1 || e.jobs[0].Res != "" {
+		t.Errorf("recipe should stop at the first failing line, got %v %q", errs, e.jobs[0].Res)
+	}
+
+	for _, makefile := range []string{"\techo orphan recipe\n", "not a target\n"} {
+		if err := NewExecutor().AddJobsFromMakefile(strings.NewReader(makefile)); !errors.Is(err, ErrInvalidGraphDescription) || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("invalid makefile %q should return an error with the line number, got %v", makefile, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// return an executed executor with build <- test <- deploy where test fails
+func getGraphTestExecutor() *JobExecutor {
+	e := NewExecutor()
+	build := e.AddJob(NamedJob{"build", TestRunnableSuccessFn})
+	test := e.AddJob(NamedJob{"test \"unit\"", func() (string, error) { return "", errors.New("failed") }})
+	deploy := e.AddJob(NamedJob{"deploy", TestRunnableSuccessFn})
+	e.AddJobDependency(test, build).AddJobDependency(deploy, test)
+	e.DagExecute()
+	return e
+}
+
+func TestJobExecutor_GetDot(t *testing.T) {
+	e := getGraphTestExecutor()
+	want := `digraph G{
+	graph [bgcolor="#121212" fontcolor="black" rankdir="RL"]
+	node [colorscheme="set312" style="filled,rounded" shape="box"]
+	edge [color="#f0f0f0"]
+	0 [label="build" color="1"]
+	1 [label="test \"unit\"" color="2"]
+	2 [label="deploy" color="3"]
+	1 -> 0
+	2 -> 1
+}`
+	if got := e.GetDot(); got != want {
+		t.Errorf("GetDot() =\n%s\nwant\n%s", got, want)
+	}
+	got := e.GetDotWithOptions(GraphOptions{RankDir: "TB", ShowState: true, StateColors: map[string]string{"failed": "red"}, BgColor: "white"})
+	for _, want := range []string{
+		`graph [bgcolor="white" fontcolor="black" rankdir="TB"]`,
+		`1 [label="test \"unit\"\n`,
+		`color="red" fontcolor="white"]`,
+		`2 [label="deploy" color="#616161" fontcolor="white"]`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("GetDotWithOptions() should contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestJobExecutor_graphExports(t *testing.T) {
+	e := getGraphTestExecutor()
+	opts := GraphOptions{RankDir: "LR", ShowState: true}
+	tests := []struct {
+		name     string
+		got      string
+		contains []string
+	}{
+		{"mermaid", e.GetMermaid(opts), []string{
+			"flowchart LR\n",
+			"\tjob1[\"test #quot;unit#quot;<br>",
+			"\tjob2[\"deploy\"]\n",
+			"\tjob1 --> job0\n",
+			"\tclass job1 failed\n",
+			"\tclassDef skipped fill:#616161,color:#fff\n",
+		}},
+		{"plantuml", e.GetPlantUML(opts), []string{
+			"@startuml\nleft to right direction\n",
+			"rectangle \"deploy\" as job2 #616161\n",
+			"job2 --> job1\n",
+			"@enduml\n",
+		}},
+		{"d2", e.GetD2(opts), []string{
+			"direction: right\n",
+			"job1: \"test \\\"unit\\\"\\n",
+			"job2: \"deploy\" {style.fill: \"#616161\"; style.font-color: \"#ffffff\"}\n",
+			"job2 -> job1\n",
+		}},
+	}
+	for _, tt := range tests {
+		for _, want := range tt.contains {
+			if !strings.Contains(tt.got, want) {
+				t.Errorf("%s export should contain %q:\n%s", tt.name, want, tt.got)
+			}
+		}
+	}
+	if got := e.GetMermaid(GraphOptions{}); strings.Contains(got, "class") || !strings.HasPrefix(got, "flowchart RL\n") {
+		t.Errorf("mermaid export without state should not contain classes:\n%s", got)
+	}
+	if got := e.GetPlantUML(GraphOptions{}); !strings.Contains(got, "job0 <-- job1\n") {
+		t.Errorf("plantuml export should follow rank direction:\n%s", got)
+	}
+}
+
+func TestJobExecutor_WriteJSONGraph(t *testing.T) {
+	var buf bytes.Buffer
+	if err := getGraphTestExecutor().WriteJSONGraph(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var graph struct {
+		Nodes []jsonGraphNode `json:"nodes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Nodes) != 3 {
+		t.Fatalf("expected 3 nodes got %d", len(graph.Nodes))
+	}
+	states := []string{"succeed", "failed", "skipped"}
+	for i, n := range graph.Nodes {
+		if n.Id != i || n.State != states[i] {
+			t.Errorf("unexpected node %+v", n)
+		}
+		if (i == 0 && len(n.DependsOn) != 0) || (i > 0 && (len(n.DependsOn) != 1 || n.DependsOn[0] != i-1)) {
+			t.Errorf("unexpected dependencies for node %d: %v", i, n.DependsOn)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// standard and bright terminal colors
+var ansiColors = []string{
+	"#000000", "#cd3131", "#0dbc79", "#e5e510", "#2472c8", "#bc3fbc", "#11a8cd", "#e5e5e5",
+	"#666666", "#f14c4c", "#23d18b", "#f5f543", "#3b8eea", "#d670d6", "#29b8db", "#ffffff",
+}
+
+var ansiEscapeRegexp = regexp.MustCompile(`\x1b\[([0-9;?]*)([a-zA-Z])`)
+
+// return the color of the 256 colors palette index n
+func ansi256Color(n int) string {
+	switch {
+	case n < 16:
+		return ansiColors[n]
+	case n < 232: // 6x6x6 cube
+		n -= 16
+		levels := []int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[n/6%6], levels[n%6])
+	case n < 256: // grayscale
+		v := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", v, v, v)
+	}
+	return ""
+}
+
+type ansiStyle struct {
+	bold, dim, italic, underline bool
+	fg, bg                       string
+}
+
+func (s ansiStyle) css() string {
+	var css []string
+	if s.bold {
+		css = append(css, "font-weight:bold")
+	}
+	if s.dim {
+		css = append(css, "opacity:.7")
+	}
+	if s.italic {
+		css = append(css, "font-style:italic")
+	}
+	if s.underline {
+		css = append(css, "text-decoration:underline")
+	}
+	if s.fg != "" {
+		css = append(css, "color:"+s.fg)
+	}
+	if s.bg != "" {
+		css = append(css, "background:"+s.bg)
+	}
+	return strings.Join(css, ";")
+}
+
+// parse an extended color (38;5;n or 38;2;r;g;b) starting at params[i],
+// return the color and the number of consumed params
+func parseAnsiExtendedColor(params []int, i int) (string, int) {
+	if i+1 < len(params) && params[i] == 5 {
+		return ansi256Color(params[i+1]), 2
+	}
+	if i+3 < len(params) && params[i] == 2 {
+		return fmt.Sprintf("#%02x%02x%02x", params[i+1]&255, params[i+2]&255, params[i+3]&255), 4
+	}
+	return "", len(params) - i
+}
+
+// apply SGR parameters to style
+func (s ansiStyle) apply(params []int) ansiStyle {
+	if len(params) == 0 {
+		return ansiStyle{}
+	}
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			s = ansiStyle{}
+		case p == 1:
+			s.bold = true
+		case p == 2:
+			s.dim = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 22:
+			s.bold, s.dim = false, false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p >= 30 && p <= 37:
+			s.fg = ansiColors[p-30]
+		case p >= 90 && p <= 97:
+			s.fg = ansiColors[p-90+8]
+		case p == 39:
+			s.fg = ""
+		case p >= 40 && p <= 47:
+			s.bg = ansiColors[p-40]
+		case p >= 100 && p <= 107:
+			s.bg = ansiColors[p-100+8]
+		case p == 49:
+			s.bg = ""
+		case p == 38 || p == 48:
+			color, n := parseAnsiExtendedColor(params, i+1)
+			if p == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+			i += n
+		}
+	}
+	return s
+}
+
+// convert text containing ANSI escape sequences to HTML, colors and styles
+// are converted to inline styled spans and other sequences are dropped
+func ansiToHTML(s string) string {
+	var sb strings.Builder
+	var style ansiStyle
+	open := false
+	write := func(text string) {
+		if text == "" {
+			return
+		}
+		sb.WriteString(html.EscapeString(text))
+	}
+	last := 0
+	for _, m := range ansiEscapeRegexp.FindAllStringSubmatchIndex(s, -1) {
+		write(s[last:m[0]])
+		last = m[1]
+		if s[m[4]:m[5]] != "m" {
+			continue
+		}
+		var params []int
+		for _, p := range strings.Split(s[m[2]:m[3]], ";") {
+			n, _ := strconv.Atoi(p)
+			params = append(params, n)
+		}
+		style = style.apply(params)
+		if open {
+			sb.WriteString("</span>")
+			open = false
+		}
+		if css := style.css(); css != "" {
+			sb.WriteString(`<span style="` + css + `">`)
+			open = true
+		}
+	}
+	write(s[last:])
+	if open {
+		sb.WriteString("</span>")
+	}
+	return sb.String()
+}
+
+type htmlReportJob struct {
+	Id        int
+	Name      string
+	Outcome   string
+	ExitCode  int
+	Err       string
+	Duration  time.Duration
+	QueueWait time.Duration
+	// position and width of the duration bar in percent of the run duration
+	BarOffset float64
+	BarWidth  float64
+	Output    template.HTML
+}
+
+type htmlReportNode struct {
+	graphNode
+	X, Y float64
+}
+
+type htmlReportEdge struct {
+	X1, Y1, X2, Y2 float64
+}
+
+type htmlReport struct {
+	Title       string
+	Date        string
+	Total       time.Duration
+	Counts      map[string]int
+	Jobs        []htmlReportJob
+	Nodes       []htmlReportNode
+	Edges       []htmlReportEdge
+	GraphWidth  float64
+	GraphHeight float64
+}
+
+const (
+	htmlNodeWidth   = 180
+	htmlNodeHeight  = 30
+	htmlNodeXMargin = 60
+	htmlNodeYMargin = 14
+)
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"color":    func(outcome string) string { return graphStateColors[outcome] },
+	"duration": formatDuration,
+	"add":      func(a, b float64) float64 { return a + b },
+	"truncate": func(width int, s string) string { return truncateString(s, width) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>
+body{font-family:sans-serif;background:#121212;color:#f0f0f0;margin:2em}
+table{border-collapse:collapse;width:100%}
+th,td{padding:4px 8px;text-align:left;vertical-align:top;border-bottom:1px solid #333}
+.badge{display:inline-block;padding:1px 6px;border-radius:4px;font-size:.85em}
+.bar{position:relative;height:12px;background:#1e1e1e;min-width:200px}
+.bar div{position:absolute;height:12px;min-width:1px}
+pre{background:#000;color:#e5e5e5;padding:8px;overflow-x:auto;margin:4px 0}
+svg{background:#1e1e1e;border-radius:4px}
+svg text{fill:#fff;font-size:12px;dominant-baseline:middle;text-anchor:middle}
+summary{cursor:pointer}
+</style></head><body>
+<h1>{{.Title}}</h1>
+<p>{{.Date}} - total: {{duration .Total}} - {{len .Jobs}} jobs:{{range $outcome, $count := .Counts}} <span class="badge" style="background:{{color $outcome}}">{{$count}} {{$outcome}}</span>{{end}}</p>
+<h2>Dependencies</h2>
+<svg width="{{.GraphWidth}}" height="{{.GraphHeight}}">
+<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#f0f0f0"/></marker></defs>
+{{- range .Edges}}
+<line x1="{{.X1}}" y1="{{.Y1}}" x2="{{.X2}}" y2="{{.Y2}}" stroke="#f0f0f0" marker-end="url(#arrow)"/>
+{{- end}}
+{{- range .Nodes}}
+<g><title>{{.Name}} ({{.Outcome}}) {{duration .Duration}}</title>
+<rect x="{{.X}}" y="{{.Y}}" width="180" height="30" rx="6" fill="{{color .Outcome}}"/>
+<text x="{{add .X 90}}" y="{{add .Y 15}}">{{truncate 24 .Name}}</text></g>
+{{- end}}
+</svg>
+<h2>Jobs</h2>
+<table>
+<tr><th>Status</th><th>Job</th><th>Exit code</th><th>Duration</th><th>Queue wait</th><th>Timeline</th></tr>
+{{- range .Jobs}}
+<tr>
+<td><span class="badge" style="background:{{color .Outcome}}">{{.Outcome}}</span></td>
+<td>{{.Name}}{{if .Err}}<br><small>{{.Err}}</small>{{end}}</td>
+<td>{{if ge .ExitCode 0}}{{.ExitCode}}{{end}}</td>
+<td>{{duration .Duration}}</td>
+<td>{{duration .QueueWait}}</td>
+<td><div class="bar"><div style="left:{{.BarOffset}}%;width:{{.BarWidth}}%;background:{{color .Outcome}}"></div></div></td>
+</tr>
+{{- if .Output}}
+<tr><td></td><td colspan="5"><details{{if eq .Outcome "failed"}} open{{end}}><summary>output</summary><pre>{{.Output}}</pre></details></td></tr>
+{{- end}}
+{{- end}}
+</table>
+</body></html>
+`))
+
+// Write a self-contained HTML report of a finished run: the dependency graph,
+// the status, exit code and duration of each job and its full output with
+// ANSI colors converted to HTML. The output of failed jobs is expanded.
+func (e *JobExecutor) WriteHTMLReport(w io.Writer) error {
+	report := htmlReport{Title: "jobExecutor report", Counts: make(map[string]int)}
+	var start, end time.Time
+	for _, j := range e.jobs {
+		j.mutex.RLock()
+		if !j.StartTime.IsZero() {
+			if start.IsZero() || j.StartTime.Before(start) {
+				start = j.StartTime
+			}
+			if j.EndTime.After(end) {
+				end = j.EndTime
+			}
+		}
+		j.mutex.RUnlock()
+	}
+	report.Total = end.Sub(start)
+	if !start.IsZero() {
+		report.Date = start.Format(time.RFC1123)
+	}
+	for _, j := range e.jobs {
+		view := j.view()
+		reportJob := htmlReportJob{
+			Id:        view.Id(),
+			Name:      view.Name(),
+			Outcome:   nodeState(j),
+			ExitCode:  view.ExitCode(),
+			Duration:  view.Duration(),
+			QueueWait: view.QueueWait(),
+			Output:    template.HTML(ansiToHTML(view.Output())),
+		}
+		if view.Err() != nil {
+			reportJob.Err = view.Err().Error()
+		}
+		if report.Total > 0 && !view.StartTime().IsZero() {
+			reportJob.BarOffset = float64(view.StartTime().Sub(start)) * 100 / float64(report.Total)
+			reportJob.BarWidth = float64(view.Duration()) * 100 / float64(report.Total)
+		}
+		report.Counts[reportJob.Outcome]++
+		report.Jobs = append(report.Jobs, reportJob)
+	}
+
+	// layout graph nodes in columns by level, dependencies on the left
+	nodes, edges := e.getGraph()
+	rows := make(map[int]int)
+	positions := make(map[int]htmlReportNode, len(nodes))
+	for _, n := range nodes {
+		node := htmlReportNode{
+			graphNode: n,
+			X:         float64(htmlNodeXMargin/2 + n.Level*(htmlNodeWidth+htmlNodeXMargin)),
+			Y:         float64(htmlNodeYMargin + rows[n.Level]*(htmlNodeHeight+htmlNodeYMargin)),
+		}
+		rows[n.Level]++
+		positions[n.Id] = node
+		report.Nodes = append(report.Nodes, node)
+		if w := node.X + htmlNodeWidth + htmlNodeXMargin/2; w > report.GraphWidth {
+			report.GraphWidth = w
+		}
+		if h := node.Y + htmlNodeHeight + htmlNodeYMargin; h > report.GraphHeight {
+			report.GraphHeight = h
+		}
+	}
+	for _, edge := range edges {
+		dep, dependent := positions[edge.To], positions[edge.From]
+		report.Edges = append(report.Edges, htmlReportEdge{
+			X1: dep.X + htmlNodeWidth, Y1: dep.Y + htmlNodeHeight/2,
+			X2: dependent.X, Y2: dependent.Y + htmlNodeHeight/2,
+		})
+	}
+	return htmlReportTemplate.Execute(w, report)
+}
+
+// Write an HTML report to filename when all jobs are done
+// (see WriteHTMLReport), errors are written to the executor error output
+func (e *JobExecutor) WithHTMLReport(filename string) *JobExecutor {
+	e.onJobsDone(func(jobs JobList) {
+		f, err := os.Create(filename)
+		if err == nil {
+			err = e.WriteHTMLReport(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(e.getErrOutput(), "can't write html report:", err)
+		}
+	})
+	return e
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_ansiToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text is escaped", "a <b> & c", "a &lt;b&gt; &amp; c"},
+		{"basic colors", "\033[31mred\033[0m normal", `<span style="color:#cd3131">red</span> normal`},
+		{"combined styles", "\033[1;42mx\033[22my\033[m", `<span style="font-weight:bold;background:#0dbc79">x</span><span style="background:#0dbc79">y</span>`},
+		{"256 and true colors", "\033[38;5;196ma\033[48;2;1;2;3mb", `<span style="color:#ff0000">a</span><span style="color:#ff0000;background:#010203">b</span>`},
+		{"other sequences are dropped", "\033[2K\033[1Aline", "line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ansiToHTML(tt.in); got != tt.want {
+				t.Errorf("ansiToHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobExecutor_WriteHTMLReport(t *testing.T) {
+	e := NewExecutor()
+	build := e.AddJob(NamedJob{"build", exec.Command("sh", "-c", `printf '\033[32mok\033[0m <done>\n'`)})
+	test := e.AddJob(NamedJob{"test", exec.Command("sh", "-c", "echo failing; exit 2")})
+	deploy := e.AddJob(NamedJob{"deploy", TestRunnableSuccessFn})
+	e.AddJobDependency(test, build).AddJobDependency(deploy, test)
+	e.DagExecute()
+
+	var buf bytes.Buffer
+	if err := e.WriteHTMLReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	for _, want := range []string{
+		`<span style="color:#0dbc79">ok</span> &lt;done&gt;`,
+		"<details open><summary>output</summary><pre>failing\n</pre></details>",
+		"<td>2</td>",
+		"1 skipped",
+		"required job failed",
+		">build</text>",
+		"<line ",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report should contain %q", want)
+		}
+	}
+	if n := strings.Count(report, "<line "); n != 2 {
+		t.Errorf("report should contain 2 edges, got %d", n)
+	}
+
+	filename := filepath.Join(t.TempDir(), "report.html")
+	var errOut bytes.Buffer
+	NewExecutor().SetErrorOutput(&errOut).AddJobFns(TestRunnableSuccessFn).WithHTMLReport(filename).Execute()
+	if content, err := os.ReadFile(filename); err != nil || !strings.Contains(string(content), "1 succeed") {
+		t.Errorf("report file should be written: %v %s", err, errOut.String())
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const (
+	TimestampNone      = 0
+	TimestampElapsed   = 1 // time elapsed since the executor started
+	TimestampWallClock = 2
+)
+
+// Options for WithInterleavedOutputOptions, colors and styles are only used
+// when ANSI escape sequences are enabled on the executor output
+type InterleavedOutputOptions struct {
+	// give each job prefix a color that stays the same across runs
+	Colors bool
+	// pad prefixes to the length of the longest job name
+	AlignPrefixes bool
+	// prefix each line with a timestamp: one of Timestamp* constants
+	Timestamps int
+	// style stderr lines differently than stdout lines. When ANSI is disabled
+	// stderr lines are marked with [stderr]. Command stdout and stderr are
+	// then read from different pipes so their relative order may change.
+	DistinctStderr bool
+}
+
+// 256 colors palette of well distinct colors readable on dark and light backgrounds
+var interleavedColors = []int{39, 208, 42, 170, 220, 75, 203, 118, 141, 214, 44, 213}
+
+// return a color escape sequence that depends only on the job name
+func jobColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("\033[38;5;%dm", interleavedColors[h.Sum32()%uint32(len(interleavedColors))])
+}
+
+// Same as WithInterleavedOutput with additional options to make outputs of
+// many concurrent jobs easier to scan.
+func (e *JobExecutor) WithInterleavedOutputOptions(opts InterleavedOutputOptions) *JobExecutor {
+	// writers of each job to flush when the job is done
+	wrapped := make(map[*job][]*prefixedWriter)
+	var mutex sync.RWMutex
+	var startTime time.Time
+	e.onJobsStart(func(jobs JobList) {
+		mutex.Lock()
+		startTime = time.Now()
+		mutex.Unlock()
+		ansi := e.useAnsi()
+		nameWidth := 0
+		if opts.AlignPrefixes {
+			for _, job := range jobs {
+				if l := len([]rune(job.Name())); l > nameWidth {
+					nameWidth = l
+				}
+			}
+		}
+		var linePrefix func() string
+		if opts.Timestamps != TimestampNone {
+			dimSeq, resetSeq := "", ""
+			if ansi {
+				dimSeq, resetSeq = "\033[2m", "\033[0m"
+			}
+			linePrefix = func() string {
+				var ts string
+				if opts.Timestamps == TimestampElapsed {
+					mutex.RLock()
+					ts = fmt.Sprintf("%8.3fs", time.Since(startTime).Seconds())
+					mutex.RUnlock()
+				} else {
+					ts = time.Now().Format("15:04:05.000")
+				}
+				return dimSeq + "[" + ts + "]" + resetSeq + " "
+			}
+		}
+		for _, job := range jobs {
+			// jobs may be run more than once (see DagWatch)
+			if _, ok := wrapped[job]; ok {
+				continue
+			}
+			prefix := job.Name() + ":"
+			if nameWidth > 0 {
+				prefix = padString(prefix, nameWidth+1)
+			}
+			prefix += " "
+			if opts.Colors && ansi {
+				prefix = jobColor(job.Name()) + prefix + "\033[0m"
+			}
+			pw := NewPrefixedWriter(e.getOutput(), prefix)
+			pw.linePrefix = linePrefix
+			errPw := pw
+			if opts.DistinctStderr {
+				errPw = NewPrefixedWriter(e.getOutput(), prefix)
+				errPw.linePrefix = linePrefix
+				if ansi {
+					errPw.style = "\033[91m"
+				} else {
+					errPw.prefix += "[stderr] "
+				}
+			}
+			mutex.Lock()
+			wrapped[job] = []*prefixedWriter{pw, errPw}
+			mutex.Unlock()
+			if job.Cmd != nil {
+				job.Cmd.Stdout = pw
+				job.Cmd.Stderr = errPw
+			} else if job.Fn != nil {
+				fn := job.Fn
+				job.Fn = func() (string, error) {
+					res, err := fn()
+					if res != "" {
+						pw.Write([]byte(res))
+					}
+					if err != nil {
+						errPw.Write([]byte(err.Error()))
+					}
+					pw.Flush()
+					errPw.Flush()
+					return res, err
+				}
+			}
+		}
+	})
+	e.onJobDone(func(jobs JobList, jobId int) {
+		mutex.RLock()
+		writers := wrapped[jobs[jobId]]
+		mutex.RUnlock()
+		for _, w := range writers {
+			w.Flush()
+		}
+	})
+	return e
+}
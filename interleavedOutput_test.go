@@ -0,0 +1,70 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_jobColor(t *testing.T) {
+	if jobColor("build") != jobColor("build") {
+		t.Error("jobColor should be stable for a given name")
+	}
+	if !strings.HasPrefix(jobColor("test"), "\033[38;5;") {
+		t.Errorf("unexpected color sequence %q", jobColor("test"))
+	}
+}
+
+func TestJobExecutor_WithInterleavedOutputOptions(t *testing.T) {
+	failFn := func() (string, error) { return "out", errors.New("oops") }
+	t.Run("aligned prefixes and stderr marker without ANSI", func(t *testing.T) {
+		var out bytes.Buffer
+		NewExecutor().SetOutput(&out).
+			AddNamedJobFn("a", failFn).
+			AddNamedJobCmd("longer", exec.Command("sh", "-c", "echo hello")).
+			WithInterleavedOutputOptions(InterleavedOutputOptions{AlignPrefixes: true, DistinctStderr: true, Colors: true}).
+			Execute()
+		got := out.String()
+		for _, want := range []string{"a:      out\n", "a:      [stderr] oops\n", "longer: hello\n"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("output should contain %q, got %q", want, got)
+			}
+		}
+		if strings.Contains(got, "\033") {
+			t.Errorf("output should not contain escape sequences: %q", got)
+		}
+	})
+	t.Run("colors, timestamps and stderr style with ANSI", func(t *testing.T) {
+		var out bytes.Buffer
+		NewExecutor().SetOutput(&out).SetAnsiEnabled(true).
+			AddNamedJobFn("a", failFn).
+			WithInterleavedOutputOptions(InterleavedOutputOptions{Colors: true, Timestamps: TimestampElapsed, DistinctStderr: true}).
+			Execute()
+		color := regexp.QuoteMeta(jobColor("a"))
+		stdout := regexp.MustCompile(`\033\[2m\[ +\d+\.\d{3}s\]\033\[0m ` + color + `a: \033\[0mout\n`)
+		stderr := regexp.MustCompile(`\033\[2m\[ +\d+\.\d{3}s\]\033\[0m ` + color + `a: \033\[0m\033\[91moops\033\[0m\n`)
+		if got := out.String(); !stdout.MatchString(got) || !stderr.MatchString(got) {
+			t.Errorf("unexpected output %q", got)
+		}
+	})
+	t.Run("wall clock timestamps", func(t *testing.T) {
+		var out bytes.Buffer
+		NewExecutor().SetOutput(&out).
+			AddNamedJobFn("a", TestRunnableSuccessFn).
+			WithInterleavedOutputOptions(InterleavedOutputOptions{Timestamps: TimestampWallClock}).
+			Execute()
+		if got := out.String(); !regexp.MustCompile(`^\[\d\d:\d\d:\d\d\.\d{3}\] a: done\n$`).MatchString(got) {
+			t.Errorf("unexpected output %q", got)
+		}
+	})
+}
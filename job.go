@@ -10,7 +10,7 @@ package jobExecutor
 import (
 	"bytes"
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
 	"reflect"
 	"runtime"
@@ -29,6 +29,7 @@ const (
 )
 
 var ErrRequiredJobFailed = fmt.Errorf("required job failed")
+var ErrJobCanceled = fmt.Errorf("job canceled")
 var ErrUndefinedTemplate = fmt.Errorf("template is not defined, see jobExecutor.setTemplate")
 
 type runnableFn func() (string, error)
@@ -41,10 +42,35 @@ type job struct {
 	Res         string
 	Err         error
 	status      int
-	StartTime   time.Time
-	Duration    time.Duration
-	DependsOn   []*job
-	mutex       sync.RWMutex
+	// time the job was queued for execution
+	EnqueueTime time.Time
+	// time all dependencies of the job were resolved
+	ReadyTime time.Time
+	// time the job started running: it acquired a concurrency slot and its
+	// dependencies were checked
+	StartTime time.Time
+	// time the job terminated
+	EndTime time.Time
+	// time spent running (EndTime - StartTime)
+	Duration   time.Duration
+	DependsOn  []*job
+	watchPaths []string
+	canceled   bool
+	// cancel killed the process of the command
+	killed   bool
+	attempts int
+	slot     int
+	logFile  string
+	tags     []string
+	// name of the stage the job belongs to, see JobExecutor.Stage
+	stage string
+	// executor run by this job, see JobExecutor.runAsJob
+	sub *JobExecutor
+	// limiters of the run the sub executor job belongs to
+	parentLimiters limiters
+	// called before running the job once its dependencies succeed
+	prepare func() error
+	mutex   sync.RWMutex
 }
 
 // ************************** public Job API **************************//
@@ -95,56 +121,306 @@ func (j *Job) Err() error {
 	return err
 }
 
+// return the time the job was queued for execution (concurrency safe)
+func (j *Job) EnqueueTime() time.Time {
+	j.job.mutex.RLock()
+	defer j.job.mutex.RUnlock()
+	return j.job.EnqueueTime
+}
+
+// return the time all dependencies of the job were resolved (concurrency safe)
+func (j *Job) ReadyTime() time.Time {
+	j.job.mutex.RLock()
+	defer j.job.mutex.RUnlock()
+	return j.job.ReadyTime
+}
+
+// return the time the job acquired a concurrency slot and started (concurrency safe)
+func (j *Job) StartTime() time.Time {
+	j.job.mutex.RLock()
+	defer j.job.mutex.RUnlock()
+	return j.job.StartTime
+}
+
+// return the time the job terminated (concurrency safe)
+func (j *Job) EndTime() time.Time {
+	j.job.mutex.RLock()
+	defer j.job.mutex.RUnlock()
+	return j.job.EndTime
+}
+
+// return the time spent running the job (concurrency safe)
+func (j *Job) Duration() time.Duration {
+	j.job.mutex.RLock()
+	defer j.job.mutex.RUnlock()
+	return j.job.Duration
+}
+
+// return the time the job waited for a concurrency slot once ready, a long
+// wait means the concurrency limit is a bottleneck (concurrency safe)
+func (j *Job) QueueWait() time.Duration { return j.job.QueueWait() }
+
+// return the path of the job log file when run with WithLogFiles (concurrency safe)
+func (j *Job) LogFile() string {
+	j.job.mutex.RLock()
+	defer j.job.mutex.RUnlock()
+	return j.job.logFile
+}
+
+// return the tags of the job (see JobExecutor.AddJobTags)
+func (j *Job) Tags() []string { return append([]string(nil), j.job.tags...) }
+
+// check the job has the given tag
+func (j *Job) HasTag(tag string) bool {
+	for _, t := range j.job.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ask the job to stop (concurrency safe)
+// a running command will be killed, a running runnableFn can't be interrupted
+// but its result will be discarded. The job will end with ErrJobCanceled
+func (j *Job) Cancel() { j.job.cancel() }
+
 // ************************** Internam Job API **************************//
 
-func (j *job) run(done func()) {
-	defer done()
+// check the dependencies of the job succeeded and mark it as running in the
+// given concurrency slot. If a dependency failed the job is done with
+// ErrRequiredJobFailed and false is returned, run must still be called.
+func (j *job) start(slot int) bool {
 	j.mutex.RLock()
 	dependsOn := j.DependsOn
 	j.mutex.RUnlock()
-	if len(dependsOn) > 0 {
-		hasDepErr := false
-		for _, job := range dependsOn {
-			if !job.IsState(JobStateSucceed) {
-				hasDepErr = true
-				break
-			}
-		}
-		if hasDepErr {
+	for _, dep := range dependsOn {
+		if !dep.IsState(JobStateSucceed) {
 			j.mutex.Lock()
+			j.StartTime = time.Now()
+			j.slot = slot
 			j.Err = ErrRequiredJobFailed
 			j.status = JobStateDone | JobStateFailed
-			j.Duration = time.Since(j.StartTime)
+			j.setEndTime()
+			j.mutex.Unlock()
+			return false
+		}
+	}
+	j.setRunning(slot)
+	return true
+}
+
+// run a job marked as running by start and call done when terminated, jobs
+// skipped by start only call done.
+// If onOutput is not nil it will be called for each line of output as soon
+// as it is available
+func (j *job) run(onOutput func(line string), done func()) {
+	defer done()
+	if j.IsState(JobStateDone) {
+		return
+	}
+	if j.prepare != nil {
+		if err := j.prepare(); err != nil {
+			j.mutex.Lock()
+			j.Err = err
+			j.status = JobStateDone | JobStateFailed
+			j.setEndTime()
 			j.mutex.Unlock()
 			return
 		}
 	}
+	var lw *lineWriter
+	if onOutput != nil {
+		lw = newLineWriter(onOutput)
+	}
 	if j.Cmd != nil {
-		var res []byte
+		var res bytes.Buffer
 		var err error
-		if j.Cmd.Stderr == nil && j.Cmd.Stdout == nil {
-			res, err = j.Cmd.CombinedOutput()
-		} else { // don't collect outputs if user already dealt with
-			err = j.Cmd.Run()
+		stdout, stderr := j.Cmd.Stdout, j.Cmd.Stderr
+		// don't collect outputs if user already dealt with
+		if stdout == nil && stderr == nil {
+			j.Cmd.Stdout = &res
+			j.Cmd.Stderr = &res
+		}
+		if lw != nil {
+			j.Cmd.Stdout = teeWriter(j.Cmd.Stdout, lw)
+			if sameWriter(stdout, stderr) { // keep them identical so exec use a single pipe
+				j.Cmd.Stderr = j.Cmd.Stdout
+			} else {
+				j.Cmd.Stderr = teeWriter(j.Cmd.Stderr, lw)
+			}
+		}
+		// start under lock so cancel can safely access the process
+		j.mutex.Lock()
+		if j.canceled {
+			err = ErrJobCanceled
+		} else {
+			err = j.Cmd.Start()
+		}
+		j.mutex.Unlock()
+		if err == nil {
+			err = j.Cmd.Wait()
+		}
+		if lw != nil {
+			lw.Flush()
 		}
 		j.mutex.Lock()
-		j.Res = string(res)
+		if j.killedByCancel() {
+			err = ErrJobCanceled
+		}
+		j.Cmd.Stdout = stdout
+		j.Cmd.Stderr = stderr
+		j.Res = res.String()
 		j.Err = err
 	} else if j.Fn != nil {
-		res, err := j.Fn()
+		j.mutex.RLock()
+		canceled := j.canceled
+		j.mutex.RUnlock()
+		var res string
+		var err error = ErrJobCanceled
+		if !canceled {
+			res, err = j.Fn()
+		}
+		if lw != nil && res != "" {
+			lw.Write([]byte(res))
+			lw.Flush()
+		}
 		j.mutex.Lock()
 		j.Res = res
 		j.Err = err
+		if j.canceled {
+			j.Err = ErrJobCanceled
+		}
+	} else {
+		j.mutex.Lock()
+		if j.canceled {
+			j.Err = ErrJobCanceled
+		}
 	}
 	if j.Err != nil {
 		j.status = JobStateDone | JobStateFailed
 	} else {
 		j.status = JobStateDone | JobStateSucceed
 	}
-	j.Duration = time.Since(j.StartTime)
+	j.setEndTime()
+	j.mutex.Unlock()
+}
+
+// check the command was stopped by cancel, a process that terminated
+// successfully before being killed is not considered canceled. It must be
+// called with the mutex locked once the command is waited for.
+func (j *job) killedByCancel() bool {
+	return j.killed && j.Cmd.ProcessState != nil && !j.Cmd.ProcessState.Success()
+}
+
+// set EndTime and Duration, must be called with the mutex locked
+func (j *job) setEndTime() {
+	j.EndTime = time.Now()
+	j.Duration = j.EndTime.Sub(j.StartTime)
+}
+
+// check a and b are the same writer without panicking on non comparable
+// writers (comparing interfaces holding them panics)
+func sameWriter(a io.Writer, b io.Writer) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ta := reflect.TypeOf(a)
+	return ta == reflect.TypeOf(b) && ta.Comparable() && a == b
+}
+
+// return a writer writing to both w and tee, or only tee if w is nil
+func teeWriter(w io.Writer, tee io.Writer) io.Writer {
+	if w == nil {
+		return tee
+	}
+	return io.MultiWriter(w, tee)
+}
+
+// mark the job as queued for execution
+func (j *job) setEnqueued() {
+	j.mutex.Lock()
+	j.EnqueueTime = time.Now()
+	j.mutex.Unlock()
+}
+
+// mark the job as ready to run: all its dependencies are resolved
+func (j *job) setReady() {
+	j.mutex.Lock()
+	j.ReadyTime = time.Now()
+	j.mutex.Unlock()
+}
+
+// mark the job as running in the given concurrency slot
+func (j *job) setRunning(slot int) {
+	j.mutex.Lock()
+	j.StartTime = time.Now()
+	j.status = JobStateRunning
+	j.slot = slot
+	j.attempts++
+	j.mutex.Unlock()
+}
+
+// Ask the job to stop: a running command will be killed, a runnableFn can't
+// be interrupted so its result will be discarded. A job that is not started
+// yet will end with ErrJobCanceled as soon as it is started. Jobs of a sub
+// executor are canceled too.
+func (j *job) cancel() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.status&JobStateDone != 0 {
+		return
+	}
+	j.canceled = true
+	// Kill fails if the process was already waited for
+	if j.Cmd != nil && j.Cmd.Process != nil && j.Cmd.Process.Kill() == nil {
+		j.killed = true
+	}
+	if j.sub != nil {
+		for _, subJob := range j.sub.jobs {
+			subJob.cancel()
+		}
+	}
+}
+
+// Put back the job in pending state so it can be run again.
+// as an exec.Cmd can't be started twice the command is replaced by a copy.
+// this methods should not be called while the job is running
+func (j *job) reset() {
+	j.mutex.Lock()
+	if j.Cmd != nil && j.Cmd.Process != nil {
+		j.Cmd = cloneCmd(j.Cmd)
+	}
+	j.Res = ""
+	j.Err = nil
+	j.status = JobStatePending
+	j.canceled = false
+	j.killed = false
+	j.logFile = ""
+	j.EnqueueTime = time.Time{}
+	j.ReadyTime = time.Time{}
+	j.StartTime = time.Time{}
+	j.EndTime = time.Time{}
+	j.Duration = 0
 	j.mutex.Unlock()
 }
 
+func cloneCmd(cmd *exec.Cmd) *exec.Cmd {
+	return &exec.Cmd{
+		Path:        cmd.Path,
+		Args:        cmd.Args,
+		Env:         cmd.Env,
+		Dir:         cmd.Dir,
+		Stdin:       cmd.Stdin,
+		Stdout:      cmd.Stdout,
+		Stderr:      cmd.Stderr,
+		ExtraFiles:  cmd.ExtraFiles,
+		SysProcAttr: cmd.SysProcAttr,
+		Err:         cmd.Err,
+		WaitDelay:   cmd.WaitDelay,
+	}
+}
+
 // Try to return the command string or the function name (using reflect)
 func (j *job) Name() string {
 	if j == nil {
@@ -153,6 +429,8 @@ func (j *job) Name() string {
 		return j.displayName
 	} else if j.Cmd != nil {
 		return strings.Join(j.Cmd.Args, " ")
+	} else if j.sub != nil {
+		return fmt.Sprintf("JobExecutor(%d jobs)", j.sub.Len())
 	} else if j.Fn != nil {
 		return runtime.FuncForPC(reflect.ValueOf(j.Fn).Pointer()).Name()
 	}
@@ -177,25 +455,59 @@ func (j *job) IsState(jobState int) bool {
 	return res
 }
 
-// Helper method for jobs execTemplate
-func tplExec(tpl *template.Template, subject interface{}) string {
+// return the time the job waited for a concurrency slot once ready
+func (j *job) QueueWait() time.Duration {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	if j.StartTime.IsZero() {
+		return 0
+	}
+	return j.StartTime.Sub(j.ReadyTime)
+}
+
+// return the exit code of a terminated command job, or -1 if not available
+func (j *job) exitCode() int {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	// ProcessState is set by Cmd.Wait without lock, it's only safe once done
+	if j.status&JobStateDone == 0 || j.Cmd == nil || j.Cmd.ProcessState == nil {
+		return -1
+	}
+	return j.Cmd.ProcessState.ExitCode()
+}
+
+// return a human readable name of the job state
+func (j *job) stateName() string {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return stateNameOf(j.status)
+}
+
+func stateNameOf(status int) string {
+	switch {
+	case status&JobStateSucceed != 0:
+		return "succeed"
+	case status&JobStateFailed != 0:
+		return "failed"
+	case status&JobStateDone != 0:
+		return "done"
+	case status&JobStateRunning != 0:
+		return "running"
+	}
+	return "pending"
+}
+
+// Helper method to execute templates
+func tplExec(tpl *template.Template, subject interface{}) (res string, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Fprintln(os.Stderr, ErrUndefinedTemplate.Error(), r)
+			err = fmt.Errorf("%w: %v", ErrUndefinedTemplate, r)
 		}
 	}()
 	var out bytes.Buffer
-	err := tpl.Execute(&out, subject)
+	err = tpl.Execute(&out, subject)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, tpl, err.Error())
-		return ""
+		return "", err
 	}
-	return out.String()
-}
-
-func (j *job) execTemplate(tpl *template.Template) string {
-	return tplExec(tpl, j)
-}
-func (jobs *JobList) execTemplate(tpl *template.Template) string {
-	return tplExec(tpl, jobs)
+	return out.String(), nil
 }
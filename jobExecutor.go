@@ -10,12 +10,13 @@ package jobExecutor
 import (
 	_ "embed"
 	"fmt"
+	"io"
 	"math"
-	"os"
 	"os/exec"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"text/template"
+	"time"
 )
 
 //go:embed output.gtpl
@@ -25,10 +26,29 @@ var ErrCyclicDependencyDetected = fmt.Errorf("cyclic dependencies detected")
 
 type jobEventHandler func(jobs JobList, jobId int)
 type jobsEventHandler func(jobs JobList)
+type jobOutputHandler func(jobs JobList, jobId int, line string)
+
+// handlers registered by users receive read-only snapshots of the jobs
+type JobViewEventHandler func(jobs JobViews, jobId int)
+type JobViewsEventHandler func(jobs []JobView)
+type JobViewOutputHandler func(job JobView, line string)
 type JobExecutor struct {
-	jobs     JobList
+	jobs JobList
+	// named jobs by name
+	names  map[string]*job
+	stages []*stage
+	// executor this one was added to as a sub executor
+	parent *JobExecutor
+	// shell used by shell jobs, see SetShell
+	shell    []string
 	opts     *executeOptions
 	template *template.Template
+	// functions added with AddTemplateFuncs
+	templateFuncs template.FuncMap
+	output        *syncWriter
+	errOutput     *syncWriter
+	// nil means auto detect
+	ansi *bool
 }
 
 // ######### template related methods ######### //
@@ -44,18 +64,18 @@ func trim(v string) string {
 	return strings.Trim(v, "\n")
 }
 
-// Template for all outputs related to jobs
+// Default template for all outputs related to jobs of executors created afterwards
 // It must define the following templates:
 //   - startSummary: which will receive a JobList
 //   - jobStatus: which will receive a single job
 //   - progressReport: which will receive a jobList
 //   - doneReport: which will receive a jobList
+//
+// It panics if the template can't be parsed, prefer JobExecutor.SetTemplateString
+// which returns an error and only affects a single executor.
 func SetTemplateString(templateString string) {
 	outputTemplate = template.Must(template.New("executor-output").
-		Funcs(template.FuncMap{
-			"indent": indent,
-			"trim":   trim,
-		}).
+		Funcs(templateFuncs(func() bool { return false })).
 		Parse(templateString),
 	)
 }
@@ -81,25 +101,60 @@ func augmentJobsHandler(fn jobsEventHandler, decoratorFn jobsEventHandler) jobsE
 	}
 }
 
-func getPrintProgress(total int, length int, colorEscSeq string) func(done int32) {
+func augmentJobOutputHandler(fn jobOutputHandler, decoratorFn jobOutputHandler) jobOutputHandler {
+	if fn == nil {
+		return decoratorFn
+	}
+	return func(jobs JobList, jobId int, line string) {
+		fn(jobs, jobId, line)
+		decoratorFn(jobs, jobId, line)
+	}
+}
+
+// return a progress bar of length characters using block characters
+// to render 1/8 of a character precision
+func progressBarString(done int, total int, length int) string {
+	if total < 1 {
+		total = 1
+	}
+	doneTotal := float64(float32(done) / float32(total) * float32(length) * 8)
+	doneStartLength := int(doneTotal / 8)
+	rest := math.Mod(doneTotal, 8)
+	barStr := strings.Repeat("█", doneStartLength)
+	if rest > 0 {
+		// add the right part of the bar starting by a single char which represent 1/8 to 8/8 of a full block
+		barStr += string(rune(9616-rest)) + strings.Repeat(" ", length-1-doneStartLength)
+	} else {
+		barStr += strings.Repeat(" ", length-doneStartLength)
+	}
+	return barStr
+}
+
+// return the progress bar line to print on w, when length is 0 or less the
+// bar fits the terminal width
+func progressBarLine(jobs JobList, w io.Writer, length int, colorEscSeq string, concurrency int) string {
+	done, total := countDoneJobs(jobs), len(jobs)
+	info := fmt.Sprintf(" %d/%d%s", done, total, formatETA(jobs, concurrency))
+	width, _, ok := getTerminalSize(w)
+	if length <= 0 {
+		length = 40
+		if ok {
+			length = width - len(info) - 2
+		}
+		if length < 10 {
+			length = 10
+		}
+	}
 	resetSeq := ""
 	if colorEscSeq != "" {
 		resetSeq = "\033[0m"
 	}
-	return func(done int32) {
-		// calc percent done
-		doneTotal := float64(float32(done) / float32(total) * float32(length) * 8)
-		doneStartLength := int(doneTotal / 8)
-		rest := math.Mod(doneTotal, 8)
-		barStr := strings.Repeat("█", doneStartLength)
-		if rest > 0 {
-			// add the right part of the bar starting by a single char which represent 1/8 to 8/8 of a full block
-			barStr += string(rune(9616-rest)) + strings.Repeat(" ", length-1-doneStartLength)
-		} else {
-			barStr += strings.Repeat(" ", length-doneStartLength)
-		}
-		fmt.Printf(" %s%s%s %d/%d\r", colorEscSeq, barStr, resetSeq, done, total)
+	line := " " + colorEscSeq + progressBarString(done, total, length) + resetSeq + info
+	if ok && 1+length+len(info) >= width {
+		// avoid wrapping which would break the next render
+		line = truncateString(" "+progressBarString(done, total, length)+info, width-1)
 	}
+	return "\033[2K" + line + "\r"
 }
 
 // return defined template associated with this executor or default template if none
@@ -114,6 +169,44 @@ func getExecutorTemplate(e *JobExecutor, name string) *template.Template {
 	return tpl
 }
 
+// render the named template for subject, errors are written to the executor error output
+// jobs are passed to templates as read-only views
+func (e *JobExecutor) execTemplate(name string, subject interface{}) string {
+	switch typed := subject.(type) {
+	case *job:
+		subject = typed.view()
+	case JobList:
+		subject = typed.views()
+	}
+	res, err := tplExec(getExecutorTemplate(e, name), subject)
+	if err != nil {
+		fmt.Fprintln(e.getErrOutput(), name, err.Error())
+	}
+	return res
+}
+
+func (e *JobExecutor) getOutput() io.Writer {
+	if e.output == nil {
+		return stdoutWriter
+	}
+	return e.output
+}
+
+func (e *JobExecutor) getErrOutput() io.Writer {
+	if e.errOutput == nil {
+		return stderrWriter
+	}
+	return e.errOutput
+}
+
+// check if ANSI escape sequences can be used on the executor output
+func (e *JobExecutor) useAnsi() bool {
+	if e.ansi != nil {
+		return *e.ansi
+	}
+	return isTerminal(e.getOutput())
+}
+
 // ######### public jobExecutor methods ######### //
 
 // Instanciate a new JobExecutor
@@ -121,14 +214,58 @@ func NewExecutor() *JobExecutor {
 	return NewExecutorWithTemplate(outputTemplate)
 }
 
+// Instanciate a new JobExecutor using the given template, it is cloned so
+// template functions can be bound to the executor (see AddTemplateFuncs)
 func NewExecutorWithTemplate(template *template.Template) *JobExecutor {
 	executor := &JobExecutor{
-		opts:     &executeOptions{},
-		template: template,
+		opts: &executeOptions{},
+	}
+	if template != nil {
+		if tpl, err := template.Clone(); err == nil {
+			executor.template = tpl.Funcs(executor.getTemplateFuncs())
+		} else {
+			executor.template = template
+		}
 	}
 	return executor
 }
 
+// Set the writer used by all With*Output methods (default to os.Stdout).
+// ANSI escape sequences (colors, cursor moves) are only emitted when w is a
+// terminal, see SetAnsiEnabled to override this detection.
+// This method can be chained.
+func (e *JobExecutor) SetOutput(w io.Writer) *JobExecutor {
+	e.output = newSyncWriter(w)
+	return e
+}
+
+// Set the writer used to report errors like template execution errors (default to os.Stderr).
+// This method can be chained.
+func (e *JobExecutor) SetErrorOutput(w io.Writer) *JobExecutor {
+	e.errOutput = newSyncWriter(w)
+	return e
+}
+
+// Force the use (or not) of ANSI escape sequences in outputs instead of
+// detecting if output is a terminal. This method can be chained.
+func (e *JobExecutor) SetAnsiEnabled(enabled bool) *JobExecutor {
+	e.ansi = &enabled
+	return e
+}
+
+// Limit the number of jobs of this executor running at once instead of using
+// the package wide limit set by SetMaxConcurrentJobs, n < 1 restores it.
+// For a sub executor it is an additional bound to the parent limit.
+// This method can be chained.
+func (e *JobExecutor) WithMaxConcurrentJobs(n int) *JobExecutor {
+	if n < 1 {
+		e.opts.limiter = nil
+	} else {
+		e.opts.limiter = make(chan struct{}, n)
+	}
+	return e
+}
+
 // Return the total number of jobs added to the jobExecutor
 func (e *JobExecutor) Len() int {
 	return len(e.jobs)
@@ -140,6 +277,7 @@ func (e *JobExecutor) Len() int {
 // supported jobs are:
 // - an *exec.Cmd
 // - a runnableFn (func() (string, error))
+// - a *JobExecutor run as a single job, see AddSubExecutor
 // - a NamedJob
 // any unsupported job type will panic
 // some examples:
@@ -155,17 +293,25 @@ func (e *JobExecutor) Len() int {
 //	job, err := executor.AddJob(&jobExecutor.NamedJob{"myjob", func() (string, error) {... }})
 //
 // the returned Job can be used to declare dependencies between Jobs
+// names of NamedJob must be unique, it will panic with ErrDuplicateJobName otherwise
 func (e *JobExecutor) AddJob(j interface{}) Job {
 	var res Job
 	switch typedJob := j.(type) {
 	case NamedJob:
+		e.checkJobName(typedJob.Name)
 		res = e.AddJob(typedJob.Job)
 		res.job.displayName = typedJob.Name
+		e.registerJobName(res.job)
 		return res
 	case *exec.Cmd:
 		res = Job{job: &job{id: e.Len(), Cmd: typedJob}}
 	case func() (string, error):
 		res = Job{job: &job{id: e.Len(), Fn: typedJob}}
+	case *JobExecutor:
+		e.attachSubExecutor(typedJob)
+		j := &job{id: e.Len(), sub: typedJob}
+		j.Fn = func() (string, error) { return typedJob.runAsJob(j.parentLimiters) }
+		res = Job{job: j}
 	default:
 		panic("unsupported job type")
 	}
@@ -201,147 +347,344 @@ func (e *JobExecutor) AddJobFns(fns ...runnableFn) *JobExecutor {
 }
 
 // Add a job function and set its output display name.
+// It panics with ErrDuplicateJobName if name is already used.
 // This method can be chained.
 func (e *JobExecutor) AddNamedJobFn(name string, fn runnableFn) *JobExecutor {
+	e.checkJobName(name)
 	e.jobs = append(e.jobs, &job{id: e.Len(), displayName: name, Fn: fn})
+	e.registerJobName(e.jobs[e.Len()-1])
 	return e
 }
 
 // Add a job command and set its output display name.
+// It panics with ErrDuplicateJobName if name is already used.
 // This method can be chained.
 func (e *JobExecutor) AddNamedJobCmd(name string, cmd *exec.Cmd) *JobExecutor {
+	e.checkJobName(name)
 	e.jobs = append(e.jobs, &job{id: e.Len(), displayName: name, Cmd: cmd})
+	e.registerJobName(e.jobs[e.Len()-1])
 	return e
 }
 
-//************************** Events **************************//
+// panic if name is already used by another named job
+func (e *JobExecutor) checkJobName(name string) {
+	if _, ok := e.names[name]; ok && name != "" {
+		panic(fmt.Errorf("%w: %q", ErrDuplicateJobName, name))
+	}
+}
 
-// Add a handler which will be called after a job is terminated
-func (e *JobExecutor) OnJobDone(fn jobEventHandler) *JobExecutor {
-	e.opts.onJobDone = augmentJobHandler(e.opts.onJobDone, fn)
+// register the display name of j so it can be retrieved with GetJob
+func (e *JobExecutor) registerJobName(j *job) {
+	if j.displayName == "" {
+		return
+	}
+	if e.names == nil {
+		e.names = make(map[string]*job)
+	}
+	e.names[j.displayName] = j
+}
+
+// Return the job with the given name, named jobs are looked up first then
+// the computed names of other jobs (see Job.Name)
+func (e *JobExecutor) GetJob(name string) (Job, bool) {
+	if j, ok := e.names[name]; ok {
+		return Job{job: j}, true
+	}
+	for _, j := range e.jobs {
+		if j.Name() == name {
+			return Job{job: j}, true
+		}
+	}
+	return Job{}, false
+}
+
+// Declare that the job named name depends on the jobs named deps (see GetJob).
+// Returns an error wrapping ErrUnknownJob if a name doesn't match any job,
+// in which case no dependency is added.
+func (e *JobExecutor) DependsOn(name string, deps ...string) error {
+	from, ok := e.GetJob(name)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownJob, name)
+	}
+	toJobs := make([]Job, len(deps))
+	for i, dep := range deps {
+		if toJobs[i], ok = e.GetJob(dep); !ok {
+			return fmt.Errorf("%w: %q required by %q", ErrUnknownJob, dep, name)
+		}
+	}
+	for _, to := range toJobs {
+		e.AddJobDependency(from, to)
+	}
+	return nil
+}
+
+// Add tags to the given job, they can be used to select jobs with JobsWithTag.
+// This method can be chained.
+func (e *JobExecutor) AddJobTags(j Job, tags ...string) *JobExecutor {
+	for _, tag := range tags {
+		if !j.HasTag(tag) {
+			j.job.tags = append(j.job.tags, tag)
+		}
+	}
 	return e
 }
 
+// Return jobs with the given tag in insertion order
+func (e *JobExecutor) JobsWithTag(tag string) []Job {
+	var res []Job
+	for _, j := range e.jobs {
+		if (&Job{job: j}).HasTag(tag) {
+			res = append(res, Job{job: j})
+		}
+	}
+	return res
+}
+
+//************************** Events **************************//
+
+// Add a handler which will be called after a job is terminated (including
+// jobs skipped because a dependency failed). Handlers may be called
+// concurrently for different jobs.
+func (e *JobExecutor) OnJobDone(fn JobViewEventHandler) *JobExecutor {
+	return e.onJobDone(func(jobs JobList, jobId int) { fn(JobViews{jobs}, jobId) })
+}
+
 // Add a handler which will be called after all jobs are terminated
-func (e *JobExecutor) OnJobsDone(fn jobsEventHandler) *JobExecutor {
+func (e *JobExecutor) OnJobsDone(fn JobViewsEventHandler) *JobExecutor {
+	return e.onJobsDone(func(jobs JobList) { fn(jobs.views()) })
+}
+
+// Add a handler which will be called before a job is started, jobs are
+// started one at a time. It is not called for jobs skipped because a
+// dependency failed.
+func (e *JobExecutor) OnJobStart(fn JobViewEventHandler) *JobExecutor {
+	return e.onJobStart(func(jobs JobList, jobId int) { fn(JobViews{jobs}, jobId) })
+}
+
+// Add a handler which will be called before any jobs is started
+func (e *JobExecutor) OnJobsStart(fn JobViewsEventHandler) *JobExecutor {
+	return e.onJobsStart(func(jobs JobList) { fn(jobs.views()) })
+}
+
+// Add a handler which will be called for each line of output of a job.
+// Command outputs are sent as they arrive, while runnableFn outputs are sent
+// when the function returns. Handlers may be called concurrently for different jobs.
+func (e *JobExecutor) OnJobOutput(fn JobViewOutputHandler) *JobExecutor {
+	return e.onJobOutput(func(jobs JobList, jobId int, line string) { fn(jobs[jobId].view(), line) })
+}
+
+// internal handlers receive the jobs themselves instead of views
+func (e *JobExecutor) onJobDone(fn jobEventHandler) *JobExecutor {
+	e.opts.onJobDone = augmentJobHandler(e.opts.onJobDone, fn)
+	return e
+}
+func (e *JobExecutor) onJobsDone(fn jobsEventHandler) *JobExecutor {
 	e.opts.onJobsDone = augmentJobsHandler(e.opts.onJobsDone, fn)
 	return e
 }
-
-// Add a handler which will be called before a job is started
-func (e *JobExecutor) OnJobStart(fn jobEventHandler) *JobExecutor {
+func (e *JobExecutor) onJobStart(fn jobEventHandler) *JobExecutor {
 	e.opts.onJobStart = augmentJobHandler(e.opts.onJobStart, fn)
 	return e
 }
-
-// Add a handler which will be called before any jobs is started
-func (e *JobExecutor) OnJobsStart(fn jobsEventHandler) *JobExecutor {
+func (e *JobExecutor) onJobsStart(fn jobsEventHandler) *JobExecutor {
 	e.opts.onJobsStart = augmentJobsHandler(e.opts.onJobsStart, fn)
 	return e
 }
+func (e *JobExecutor) onJobOutput(fn jobOutputHandler) *JobExecutor {
+	e.opts.onJobOutput = augmentJobOutputHandler(e.opts.onJobOutput, fn)
+	return e
+}
 
 //************************** Outputs  **************************//
 
 // Output a summary of jobs that will be run
 func (e *JobExecutor) WithStartSummary() *JobExecutor {
-	e.OnJobsStart(func(jobs JobList) {
-		fmt.Print(jobs.execTemplate(getExecutorTemplate(e, "startSummary")))
+	e.onJobsStart(func(jobs JobList) {
+		fmt.Fprint(e.getOutput(), e.execTemplate("startSummary", jobs))
 	})
 	return e
 }
 
 // Output a line to say a job is starting
 func (e *JobExecutor) WithStartOutput() *JobExecutor {
-	e.OnJobStart(func(jobs JobList, jobId int) {
-		fmt.Print("Starting " + jobs[jobId].execTemplate(getExecutorTemplate(e, "jobStatusLine")))
+	e.onJobStart(func(jobs JobList, jobId int) {
+		fmt.Fprint(e.getOutput(), "Starting "+e.execTemplate("jobStatusLine", jobs[jobId]))
 	})
 	return e
 }
 
 // Display full jobStatus as they arrive
 func (e *JobExecutor) WithFifoOutput() *JobExecutor {
-	e.OnJobDone(func(jobs JobList, jobId int) {
-		fmt.Print(jobs[jobId].execTemplate(getExecutorTemplate(e, "jobStatusFull")))
+	e.onJobDone(func(jobs JobList, jobId int) {
+		fmt.Fprint(e.getOutput(), e.execTemplate("jobStatusFull", jobs[jobId]))
 	})
 	return e
 }
 
 // Display doneReport when all jobs are Done
 func (e *JobExecutor) WithOrderedOutput() *JobExecutor {
-	e.OnJobsDone(func(jobs JobList) {
-		fmt.Print(jobs.execTemplate(getExecutorTemplate(e, "doneReport")))
+	e.onJobsDone(func(jobs JobList) {
+		fmt.Fprint(e.getOutput(), e.execTemplate("doneReport", jobs))
 	})
 	return e
 }
 
-// Print stdout and stderr of command directly to stdout as they arrive
+// Print stdout and stderr of command directly to the output as they arrive
 // prefixing the output with the job name It overrides cmd.Stdin and cmd.Stdout
 // so it won't work well with other With*Output methods that rely on collecting
 // them to display them later (typically WithOrderedOutput will have nothing
-// to display)
+// to display). See WithInterleavedOutputOptions for colors, timestamps and more
 func (e *JobExecutor) WithInterleavedOutput() *JobExecutor {
-	e.OnJobsStart(func(jobs JobList) {
-		for _, job := range jobs {
-			pw := NewPrefixedWriter(os.Stdout, job.Name()+": ")
-			if job.Cmd != nil {
-				job.Cmd.Stdout = pw
-				job.Cmd.Stderr = pw
-			} else if job.Fn != nil {
-				fn := job.Fn
-				job.Fn = func() (string, error) {
-					res, err := fn()
-					fn = nil
-					if res != "" {
-						pw.Write([]byte(res))
-					}
-					if err != nil {
-						pw.Write([]byte(err.Error()))
-					}
-					return res, err
-				}
-			}
-		}
-	})
-	return e
+	return e.WithInterleavedOutputOptions(InterleavedOutputOptions{})
 }
 
 // Display a job status report updated each time a job start or end
 // be careful when dealing with other handler that generate output
-// as it will potentially break progress output
+// as it will potentially break progress output.
+// The report is redrawn when the terminal is resized and collapses to a
+// summary followed by running jobs when it doesn't fit the terminal height.
+// When output is not a terminal only the status line of the updated job is printed
 func (e *JobExecutor) WithOngoingStatusOutput() *JobExecutor {
-	e.OnJobsStart(func(jobs JobList) {
-		fmt.Print(jobs.execTemplate(getExecutorTemplate(e, "startProgressReport")))
+	var mutex sync.Mutex
+	var stop chan struct{}
+	var runningJobs JobList
+	lastLines := 0 // number of lines to rewrite on next render
+	// return the report as is or collapsed if it doesn't fit the terminal
+	fitReport := func(jobs JobList, report string) (string, bool) {
+		if _, height, ok := getTerminalSize(e.getOutput()); ok && strings.Count(report, "\n") >= height {
+			return e.collapsedProgressReport(jobs, height-1), false
+		}
+		return report, true
+	}
+	render := func(jobs JobList) {
+		report, _ := fitReport(jobs, e.execTemplate("progressReport", jobs))
+		esc := "\033[J"
+		if lastLines > 0 {
+			esc = fmt.Sprintf("\033[%dA\033[J", lastLines) // clean sequence
+		}
+		fmt.Fprint(e.getOutput(), esc+report)
+		lastLines = strings.Count(report, "\n")
+	}
+	e.onJobsStart(func(jobs JobList) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !e.useAnsi() {
+			fmt.Fprint(e.getOutput(), e.execTemplate("startProgressReport", jobs))
+			return
+		}
+		if report, fit := fitReport(jobs, e.execTemplate("startProgressReport", jobs)); fit {
+			fmt.Fprint(e.getOutput(), report)
+			lastLines = strings.Count(e.execTemplate("progressReport", jobs), "\n")
+		} else {
+			lastLines = 0
+			render(jobs)
+		}
+		runningJobs = jobs
+		stop = make(chan struct{})
+		onTerminalResize(stop, func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if runningJobs != nil {
+				render(runningJobs)
+			}
+		})
 	})
 	printProgress := func(jobs JobList, jobId int) {
-		esc := fmt.Sprintf("\033[%dA\033[J", len(jobs)) // clean sequence
-		fmt.Print(esc + jobs.execTemplate(getExecutorTemplate(e, "progressReport")))
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !e.useAnsi() {
+			fmt.Fprint(e.getOutput(), e.execTemplate("jobStatusLine", jobs[jobId]))
+			return
+		}
+		render(jobs)
 	}
-	e.OnJobDone(printProgress)
-	e.OnJobStart(printProgress)
+	e.onJobDone(printProgress)
+	e.onJobStart(printProgress)
+	e.onJobsDone(func(jobs JobList) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if stop != nil {
+			close(stop)
+			stop = nil
+		}
+		runningJobs = nil
+	})
 	return e
 }
 
-//   - length is the number of characters used to print the progress bar
+//   - length is the number of characters used to print the progress bar,
+//     0 or less to fit the terminal width
 //   - keepOnDone determines if the progress bar should be kept on the screen when done or not
 //   - colorEscSeq is an ANSII terminal escape sequence ie: "\033[32;40m"
 //     you can set the background color for the empty part of the bar (black in the given example)
 //     and the foreground color for the filled part of the bar (green in the given example)
+//
+// An estimated remaining time is displayed once some jobs are completed.
+// When output is not a terminal a plain progress line is printed every
+// PlainProgressInterval instead.
 func (e *JobExecutor) WithProgressBarOutput(length int, keepOnDone bool, colorEscSeq string) *JobExecutor {
-	var doneCount atomic.Int32
-	var printProgress func(done int32)
-	e.OnJobsStart(func(jobs JobList) {
-		printProgress = getPrintProgress(e.Len(), length, colorEscSeq)
+	var mutex sync.Mutex
+	var stop chan struct{}
+	var stopped chan struct{}
+	var runningJobs JobList
+	render := func(jobs JobList) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if runningJobs == nil {
+			return
+		}
+		fmt.Fprint(e.getOutput(), progressBarLine(jobs, e.getOutput(), length, colorEscSeq, e.maxConcurrency()))
+	}
+	printPlain := func(jobs JobList) {
+		fmt.Fprintf(e.getOutput(), "%d/%d jobs done%s\n", countDoneJobs(jobs), len(jobs), formatETA(jobs, e.maxConcurrency()))
+	}
+	e.onJobsStart(func(jobs JobList) {
+		mutex.Lock()
+		runningJobs = jobs
+		stop = make(chan struct{})
+		mutex.Unlock()
+		if e.useAnsi() {
+			onTerminalResize(stop, func() { render(jobs) })
+			render(jobs)
+			return
+		}
+		stopped = make(chan struct{})
+		go func(stop chan struct{}, stopped chan struct{}) {
+			defer close(stopped)
+			ticker := time.NewTicker(PlainProgressInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					printPlain(jobs)
+				}
+			}
+		}(stop, stopped)
 	})
-	e.OnJobDone(func(jobs JobList, jobId int) {
-		doneCount.Add(1)
-		printProgress(doneCount.Load())
+	e.onJobStart(func(jobs JobList, jobId int) {
+		if e.useAnsi() {
+			render(jobs)
+		}
+	})
+	e.onJobDone(func(jobs JobList, jobId int) {
+		if e.useAnsi() {
+			render(jobs)
+		}
 	})
-	e.OnJobStart(func(jobs JobList, jobId int) { printProgress(doneCount.Load()) })
-	e.OnJobsDone(func(jobs JobList) {
-		if keepOnDone {
-			fmt.Print("\n") // go to next line
+	e.onJobsDone(func(jobs JobList) {
+		mutex.Lock()
+		close(stop)
+		runningJobs = nil
+		mutex.Unlock()
+		if !e.useAnsi() {
+			<-stopped
+			printPlain(jobs)
+		} else if keepOnDone {
+			fmt.Fprint(e.getOutput(), "\n") // go to next line
 		} else {
-			fmt.Print("\033[2K") // clear line
+			fmt.Fprint(e.getOutput(), "\033[2K") // clear line
 		}
 	})
 	return e
@@ -351,19 +694,20 @@ func (e *JobExecutor) WithProgressBarOutput(length int, keepOnDone bool, colorEs
 
 // Effectively execute jobs and return collected errors as JobsError
 func (e *JobExecutor) Execute() JobsError {
-	var errs = make([]error, e.Len())
-	var res = make(JobsError, e.Len())
-	e.OnJobDone(func(jobs JobList, jobId int) {
-		err := jobs[jobId].Err
-		if err != nil {
-			errs[jobId] = err
-		}
-	})
+	e.opts.parentLimiters = nil
 	execute(e.jobs, *e.opts)
-	for jobId, err := range errs {
-		if err != nil {
-			res[jobId] = err
+	return e.jobsErrors()
+}
+
+// return errors of the jobs indexed by job id
+func (e *JobExecutor) jobsErrors() JobsError {
+	res := make(JobsError, e.Len())
+	for jobId, j := range e.jobs {
+		j.mutex.RLock()
+		if j.Err != nil {
+			res[jobId] = j.Err
 		}
+		j.mutex.RUnlock()
 	}
 	return res
 }
@@ -374,6 +718,13 @@ func (e *JobExecutor) AddJobDependency(from Job, to Job) *JobExecutor {
 	return e //, nil
 }
 
+// Register paths to watch for the given job when using DagWatch.
+// paths can be files, directories (watched recursively) or glob patterns
+func (e *JobExecutor) AddJobWatchPaths(j Job, paths ...string) *JobExecutor {
+	j.job.watchPaths = append(j.job.watchPaths, paths...)
+	return e
+}
+
 // Check that the jobs registered in the executor don't make a cyclic dependency
 // (use Kahn's topological sort algorithm)
 func (e *JobExecutor) IsAcyclic() bool {
@@ -417,56 +768,30 @@ func (e *JobExecutor) IsAcyclic() bool {
 	return index == length
 }
 
+// Effectively execute jobs respecting dependencies and return collected errors as JobsError
 func (e *JobExecutor) DagExecute() JobsError {
-	var errs = make([]error, e.Len())
-	var res = make(JobsError, e.Len())
-	e.OnJobDone(func(jobs JobList, jobId int) {
-		err := jobs[jobId].Err
-		if err != nil {
-			errs[jobId] = err
-		}
-	})
+	return e.dagExecute(nil)
+}
+
+// parentLimiters are the limiters of the parent run when the executor runs
+// as a job of another executor, its jobs then share the parent concurrency
+func (e *JobExecutor) dagExecute(parentLimiters limiters) JobsError {
 	if !e.IsAcyclic() {
+		res := make(JobsError, e.Len())
 		for jobId := range e.jobs {
 			res[jobId] = ErrCyclicDependencyDetected
 		}
 		return res
 	}
 	// no cyclic dependency detected call execute
+	// kept in the options so outputs know the concurrency of the current run
+	e.opts.parentLimiters = parentLimiters
 	dagExecute(e.jobs, *e.opts)
-	for jobId, err := range errs {
-		if err != nil {
-			res[jobId] = err
-		}
-	}
-	return res
+	return e.jobsErrors()
 }
 
 // return a graphviz dot representation of the execution graph you can render it
 // using graphviz or pasting output to https://dreampuf.github.io/GraphvizOnline/
 func (e *JobExecutor) GetDot() string {
-	out := []string{`digraph G{
-	graph [bgcolor="#121212" fontcolor="black" rankdir="RL"]
-	node [colorscheme="set312" style="filled,rounded" shape="box"]
-	edge [color="#f0f0f0"]`}
-	for _, j := range e.jobs {
-		out = append(out, fmt.Sprintf("\t%d [label=\"%s\" color=\"%d\"]", j.id, j.Name(), j.id%12+1))
-	}
-	for _, j := range e.jobs {
-		for _, dep := range j.DependsOn {
-			out = append(out, fmt.Sprintf("\t%d -> %d", j.id, dep.id))
-		}
-	}
-	// finally group all nodes without dependencies
-	noDepNodes := []string{}
-	for _, j := range e.jobs {
-		if len(j.DependsOn) == 0 {
-			noDepNodes = append(noDepNodes, fmt.Sprintf("%d", j.id))
-		}
-	}
-	if len(noDepNodes) > 1 {
-		out = append(out, fmt.Sprintf("\t{rank=same; %s}", strings.Join(noDepNodes, ";")))
-	}
-
-	return strings.Join(out, "\n") + "\n}"
+	return e.GetDotWithOptions(GraphOptions{})
 }
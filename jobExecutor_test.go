@@ -8,12 +8,17 @@ SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
 package jobExecutor
 
 import (
+	"bytes"
 	_ "embed"
 	"errors"
 	"os/exec"
+	"reflect"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var TestRunnableSuccessFn = func() (string, error) { return "done", nil }
@@ -85,6 +90,90 @@ func TestJobExecutor_AddJob(t *testing.T) {
 	}
 }
 
+func TestJobExecutor_GetJob(t *testing.T) {
+	e := NewExecutor().
+		AddNamedJobFn("build", TestRunnableSuccessFn).
+		AddJobCmds(exec.Command("echo", "hello"))
+	test := e.AddJob(NamedJob{"test", TestRunnableSuccessFn})
+	if j, ok := e.GetJob("test"); !ok || j.Id() != test.Id() {
+		t.Errorf("GetJob(\"test\") should return the named job, got %v %v", j.Id(), ok)
+	}
+	if j, ok := e.GetJob("echo hello"); !ok || j.Id() != 1 {
+		t.Errorf("GetJob() should fallback on computed names")
+	}
+	if _, ok := e.GetJob("unknown"); ok {
+		t.Errorf("GetJob() should not find unknown jobs")
+	}
+
+	for name, add := range map[string]func(){
+		"AddNamedJobFn":  func() { e.AddNamedJobFn("build", TestRunnableSuccessFn) },
+		"AddNamedJobCmd": func() { e.AddNamedJobCmd("test", exec.Command("exit")) },
+		"NamedJob":       func() { e.AddJob(NamedJob{"build", TestRunnableSuccessFn}) },
+	} {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, ErrDuplicateJobName) {
+					t.Errorf("%s should panic with ErrDuplicateJobName on duplicate name, got %v", name, err)
+				}
+			}()
+			add()
+		}()
+	}
+	if e.Len() != 3 {
+		t.Errorf("duplicate jobs should not be added, got %d jobs", e.Len())
+	}
+}
+
+func TestJobExecutor_DependsOn(t *testing.T) {
+	e := NewExecutor().
+		AddNamedJobFn("build", TestRunnableSuccessFn).
+		AddNamedJobFn("lint", TestRunnableSuccessFn).
+		AddNamedJobFn("test", TestRunnableSuccessFn)
+	if err := e.DependsOn("test", "build", "lint"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	test, _ := e.GetJob("test")
+	if deps := test.job.DependsOn; len(deps) != 2 || deps[0].Name() != "build" || deps[1].Name() != "lint" {
+		t.Errorf("test should depend on build and lint, got %v", deps)
+	}
+	if err := e.DependsOn("unknown", "build"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("unknown job should return ErrUnknownJob, got %v", err)
+	}
+	if err := e.DependsOn("build", "lint", "unknown"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("unknown dependency should return ErrUnknownJob, got %v", err)
+	}
+	if build, _ := e.GetJob("build"); len(build.job.DependsOn) != 0 {
+		t.Errorf("no dependency should be added on error")
+	}
+}
+
+func TestJobExecutor_JobsWithTag(t *testing.T) {
+	e := NewExecutor()
+	unit := e.AddJob(NamedJob{"unit", TestRunnableSuccessFn})
+	e2e := e.AddJob(NamedJob{"e2e", TestRunnableSuccessFn})
+	api := e.AddJob(NamedJob{"api", TestRunnableSuccessFn})
+	e.AddJobTags(unit, "test").
+		AddJobTags(e2e, "test", "integration", "test").
+		AddJobTags(api, "integration")
+	if tags := e2e.Tags(); len(tags) != 2 || tags[0] != "test" || tags[1] != "integration" {
+		t.Errorf("tags should not be duplicated, got %v", tags)
+	}
+	if !e2e.HasTag("integration") || unit.HasTag("integration") {
+		t.Errorf("HasTag() returned unexpected results")
+	}
+	jobs := e.JobsWithTag("integration")
+	if len(jobs) != 2 || jobs[0].Name() != "e2e" || jobs[1].Name() != "api" {
+		t.Errorf("JobsWithTag() should return tagged jobs in insertion order, got %v", jobs)
+	}
+	if jobs := e.JobsWithTag("unknown"); len(jobs) != 0 {
+		t.Errorf("JobsWithTag() should return no jobs for unknown tags, got %v", jobs)
+	}
+	if tags := e2e.View().Tags(); len(tags) != 2 {
+		t.Errorf("JobView should expose tags, got %v", tags)
+	}
+}
+
 func TestJobExecutorEvents(t *testing.T) {
 	var startsCalled int
 	var startCalled int
@@ -93,35 +182,35 @@ func TestJobExecutorEvents(t *testing.T) {
 	mutex := &sync.Mutex{}
 	executor := NewExecutor()
 	errs := executor.AddJobFns(TestRunnableSuccessFn, TestRunnableFailFn).
-		OnJobsStart(func(jobs JobList) {
+		OnJobsStart(func(jobs []JobView) {
 			if !(jobs[0].IsState(JobStatePending) && jobs[1].IsState(JobStatePending)) {
 				t.Fatal("onJobsStart called with jobs that are not in pending state")
 			}
 			startsCalled++
 		}).
-		OnJobStart(func(jobs JobList, jobId int) {
-			if !jobs[jobId].IsState(JobStateRunning) {
+		OnJobStart(func(jobs JobViews, jobId int) {
+			if !jobs.At(jobId).IsState(JobStateRunning) {
 				t.Fatal("onJobStart called with job that is not in running state")
 			}
 			mutex.Lock()
 			startCalled++
 			mutex.Unlock()
 		}).
-		OnJobDone(func(jobs JobList, jobId int) {
-			if !jobs[jobId].IsState(JobStateDone) {
+		OnJobDone(func(jobs JobViews, jobId int) {
+			if jobs.Len() != 2 || !jobs.At(jobId).IsState(JobStateDone) {
 				t.Fatal("onJobDone called with job that is not in done state")
 			}
-			if jobId == 0 && !jobs[jobId].IsState(JobStateSucceed) {
+			if jobId == 0 && !jobs.At(jobId).IsState(JobStateSucceed) {
 				t.Fatal("onJobDone job is not properly marked as succeed")
 			}
-			if jobId == 1 && !jobs[jobId].IsState(JobStateDone) {
+			if jobId == 1 && !jobs.All()[jobId].IsState(JobStateDone) {
 				t.Fatal("onJobDone job is not properly marked as failed")
 			}
 			mutex.Lock()
 			doneCalled++
 			mutex.Unlock()
 		}).
-		OnJobsDone(func(jobs JobList) {
+		OnJobsDone(func(jobs []JobView) {
 			donesCalled++
 		}).
 		Execute()
@@ -149,14 +238,14 @@ func TestJobExecutorEventsHandlers(t *testing.T) {
 	var secondCalled int
 
 	executor := NewExecutor().AddJobFns(TestRunnableSuccessFn).
-		OnJobsStart(func(JobList) { firstCalled++ }).
-		OnJobsStart(func(JobList) { secondCalled++ }).
-		OnJobStart(func(JobList, int) { firstCalled++ }).
-		OnJobStart(func(JobList, int) { secondCalled++ }).
-		OnJobDone(func(JobList, int) { firstCalled++ }).
-		OnJobDone(func(JobList, int) { secondCalled++ }).
-		OnJobsDone(func(JobList) { firstCalled++ }).
-		OnJobsDone(func(JobList) { secondCalled++ })
+		OnJobsStart(func([]JobView) { firstCalled++ }).
+		OnJobsStart(func([]JobView) { secondCalled++ }).
+		OnJobStart(func(JobViews, int) { firstCalled++ }).
+		OnJobStart(func(JobViews, int) { secondCalled++ }).
+		OnJobDone(func(JobViews, int) { firstCalled++ }).
+		OnJobDone(func(JobViews, int) { secondCalled++ }).
+		OnJobsDone(func([]JobView) { firstCalled++ }).
+		OnJobsDone(func([]JobView) { secondCalled++ })
 
 	executor.Execute()
 
@@ -165,6 +254,34 @@ func TestJobExecutorEventsHandlers(t *testing.T) {
 	}
 }
 
+func TestJobExecutorEventsSkippedJobs(t *testing.T) {
+	var out strings.Builder
+	var started, done []string
+	var running int32
+	e := NewExecutor().SetOutput(&out).WithStartOutput()
+	failed := e.AddJob(NamedJob{"failed", TestRunnableFailFn})
+	skipped := e.AddJob(NamedJob{"skipped", TestRunnableSuccessFn})
+	e.AddJobDependency(skipped, failed)
+	var mutex sync.Mutex
+	e.OnJobStart(func(jobs JobViews, jobId int) {
+		if atomic.AddInt32(&running, 1) != 1 {
+			t.Error("OnJobStart handlers should not be called concurrently")
+		}
+		started = append(started, jobs.At(jobId).Name())
+		atomic.AddInt32(&running, -1)
+	}).OnJobDone(func(jobs JobViews, jobId int) {
+		mutex.Lock()
+		done = append(done, jobs.At(jobId).Name())
+		mutex.Unlock()
+	}).DagExecute()
+	if !reflect.DeepEqual(started, []string{"failed"}) || !reflect.DeepEqual(done, []string{"failed", "skipped"}) {
+		t.Errorf("OnJobStart should not be called for skipped jobs, got started %v done %v", started, done)
+	}
+	if strings.Contains(out.String(), "skipped") {
+		t.Errorf("WithStartOutput should not print skipped jobs, got %q", out.String())
+	}
+}
+
 func TestJobExecutor_Execute(t *testing.T) {
 	errs := NewExecutor().
 		AddJobFns(TestRunnableSuccessFn, TestRunnableFailFn, TestRunnableFailFn).
@@ -306,3 +423,54 @@ func TestJobExecutor_DagExecute(t *testing.T) {
 		}
 	}
 }
+
+func TestJobExecutor_JobTimes(t *testing.T) {
+	e := NewExecutor()
+	jobs := e.AddJobs(
+		func() (string, error) { time.Sleep(10 * time.Millisecond); return "", nil },
+		TestRunnableSuccessFn,
+	)
+	e.AddJobDependency(jobs[1], jobs[0])
+	e.DagExecute()
+	for _, j := range jobs {
+		if j.EnqueueTime().IsZero() || j.ReadyTime().Before(j.EnqueueTime()) || j.StartTime().Before(j.ReadyTime()) || j.EndTime().Before(j.StartTime()) {
+			t.Fatalf("job %s times are not ordered: enqueued %v, ready %v, started %v, ended %v", j.Name(), j.EnqueueTime(), j.ReadyTime(), j.StartTime(), j.EndTime())
+		}
+		if j.Duration() != j.EndTime().Sub(j.StartTime()) || j.QueueWait() != j.StartTime().Sub(j.ReadyTime()) {
+			t.Fatalf("job %s durations don't match its times", j.Name())
+		}
+	}
+	if jobs[1].ReadyTime().Before(jobs[0].EndTime()) {
+		t.Fatal("job should not be ready before its dependency is done")
+	}
+}
+
+func TestJobExecutor_SetOutput(t *testing.T) {
+	var out, errOut bytes.Buffer
+	e := NewExecutor().SetOutput(&out).SetErrorOutput(&errOut).
+		AddNamedJobFn("success", TestRunnableSuccessFn).
+		AddNamedJobFn("fail", TestRunnableFailFn).
+		WithOngoingStatusOutput().
+		WithProgressBarOutput(10, true, "\033[32m").
+		WithOrderedOutput()
+	e.Execute()
+	got := out.String()
+	if strings.Contains(got, "\033") {
+		t.Errorf("output should not contain ANSI escape sequences when not a terminal: %q", got)
+	}
+	if !strings.Contains(got, "2 jobs terminated:") || !strings.Contains(got, "test error") {
+		t.Errorf("output should contain the done report: %q", got)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("unexpected error output: %q", errOut.String())
+	}
+
+	out.Reset()
+	e2 := NewExecutor().SetOutput(&out).SetAnsiEnabled(true).
+		AddJobFns(TestRunnableSuccessFn).
+		WithOngoingStatusOutput()
+	e2.Execute()
+	if !strings.Contains(out.String(), "\033[1A") {
+		t.Errorf("output should contain ANSI escape sequences when forced: %q", out.String())
+	}
+}
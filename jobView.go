@@ -0,0 +1,161 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import "time"
+
+// JobView is a read-only snapshot of a job at the time it was taken, it is
+// what templates and event handlers receive so they can't race with or
+// alter the running jobs.
+type JobView struct {
+	id          int
+	name        string
+	isCmd       bool
+	status      int
+	res         string
+	err         error
+	exitCode    int
+	enqueueTime time.Time
+	readyTime   time.Time
+	startTime   time.Time
+	endTime     time.Time
+	duration    time.Duration
+	deps        []int
+	attempts    int
+	logFile     string
+	tags        []string
+	stage       string
+}
+
+// return a snapshot of the job
+func (j *job) view() JobView {
+	exitCode := j.exitCode()
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	v := JobView{
+		id:          j.id,
+		name:        j.Name(),
+		isCmd:       j.Cmd != nil,
+		status:      j.status,
+		res:         j.Res,
+		err:         j.Err,
+		exitCode:    exitCode,
+		enqueueTime: j.EnqueueTime,
+		readyTime:   j.ReadyTime,
+		startTime:   j.StartTime,
+		endTime:     j.EndTime,
+		duration:    j.Duration,
+		attempts:    j.attempts,
+		logFile:     j.logFile,
+		tags:        j.tags,
+		stage:       j.stage,
+	}
+	for _, dep := range j.DependsOn {
+		v.deps = append(v.deps, dep.id)
+	}
+	return v
+}
+
+// return a snapshot of all jobs
+func (jobs JobList) views() []JobView {
+	views := make([]JobView, len(jobs))
+	for i, j := range jobs {
+		views[i] = j.view()
+	}
+	return views
+}
+
+// JobViews gives job event handlers access to the jobs of a run, snapshots are
+// only taken when requested so handlers don't pay for jobs they don't look at
+type JobViews struct {
+	jobs JobList
+}
+
+// return the number of jobs
+func (v JobViews) Len() int { return len(v.jobs) }
+
+// return a snapshot of the job with the given id
+func (v JobViews) At(jobId int) JobView { return v.jobs[jobId].view() }
+
+// return a snapshot of all jobs
+func (v JobViews) All() []JobView { return v.jobs.views() }
+
+// return a snapshot of the job
+func (j *Job) View() JobView { return j.job.view() }
+
+// return internal job Id, correspond to insertion order in an executor
+func (v JobView) Id() int { return v.id }
+
+// return the assigned name of a job or a computed one
+func (v JobView) Name() string { return v.name }
+
+// check the job is of *exec.Cmd type, if not it is a func() (string, error)
+func (v JobView) IsCmdJob() bool { return v.isCmd }
+
+// check the job was in the given JobState
+//
+//	view.IsState(jobExecutor.JobStateSucceed)
+func (v JobView) IsState(state int) bool {
+	if state == 0 {
+		return v.status == 0
+	}
+	return v.status&state != 0
+}
+
+// return a human readable state: pending, running, succeed or failed
+func (v JobView) State() string { return stateNameOf(v.status) }
+
+// return the combined output of the job (only after execution)
+func (v JobView) Output() string { return v.res }
+
+// same as Output, kept so templates can use .Res
+func (v JobView) Res() string { return v.res }
+
+// return the error returned by the job if any (only after execution)
+func (v JobView) Err() error { return v.err }
+
+// return the exit code of a terminated command job, or -1 if not available
+func (v JobView) ExitCode() int { return v.exitCode }
+
+// return ids of the jobs this job depends on
+func (v JobView) Deps() []int { return append([]int(nil), v.deps...) }
+
+// return the tags of the job
+func (v JobView) Tags() []string { return append([]string(nil), v.tags...) }
+
+// return the name of the stage the job belongs to or an empty string
+func (v JobView) Stage() string { return v.stage }
+
+// return the number of times the job was started
+func (v JobView) Attempts() int { return v.attempts }
+
+// return the path of the job log file when run with WithLogFiles
+func (v JobView) LogFile() string { return v.logFile }
+
+// return the time the job was queued for execution
+func (v JobView) EnqueueTime() time.Time { return v.enqueueTime }
+
+// return the time all dependencies of the job were resolved
+func (v JobView) ReadyTime() time.Time { return v.readyTime }
+
+// return the time the job acquired a concurrency slot and started
+func (v JobView) StartTime() time.Time { return v.startTime }
+
+// return the time the job terminated
+func (v JobView) EndTime() time.Time { return v.endTime }
+
+// return the time spent running the job
+func (v JobView) Duration() time.Duration { return v.duration }
+
+// return the time the job waited for a concurrency slot once ready
+func (v JobView) QueueWait() time.Duration {
+	if v.startTime.IsZero() {
+		return 0
+	}
+	return v.startTime.Sub(v.readyTime)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"os/exec"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestJobView(t *testing.T) {
+	e := NewExecutor()
+	build := e.AddJob(NamedJob{"build", exec.Command("sh", "-c", "echo built; exit 3")})
+	test := e.AddJob(NamedJob{"test", TestRunnableSuccessFn})
+	e.AddJobDependency(test, build)
+
+	pending := test.View()
+	var mutex sync.Mutex
+	var views []JobView
+	var lines []string
+	e.OnJobDone(func(jobs JobViews, jobId int) {
+		mutex.Lock()
+		views = append(views, jobs.At(jobId))
+		mutex.Unlock()
+	}).OnJobOutput(func(job JobView, line string) {
+		mutex.Lock()
+		lines = append(lines, job.Name()+": "+line)
+		mutex.Unlock()
+	})
+	e.DagExecute()
+
+	if pending.State() != "pending" || pending.Output() != "" || !pending.IsState(JobStatePending) {
+		t.Errorf("view should not change once taken, got state %s", pending.State())
+	}
+	if len(views) != 2 || views[0].Name() != "build" || views[1].Name() != "test" {
+		t.Fatalf("unexpected views %v", views)
+	}
+	b, tv := views[0], views[1]
+	if b.State() != "failed" || !b.IsState(JobStateFailed) || b.ExitCode() != 3 || b.Err() == nil || b.Output() != "built\n" || b.Res() != b.Output() || !b.IsCmdJob() {
+		t.Errorf("unexpected build view: state %s, exit %d, err %v, output %q", b.State(), b.ExitCode(), b.Err(), b.Output())
+	}
+	if b.StartTime().IsZero() || b.EndTime().Before(b.StartTime()) || b.Duration() != b.EndTime().Sub(b.StartTime()) || b.Attempts() != 1 {
+		t.Errorf("unexpected build view times")
+	}
+	if !reflect.DeepEqual(tv.Deps(), []int{build.Id()}) || tv.Err() != ErrRequiredJobFailed || tv.ExitCode() != -1 {
+		t.Errorf("unexpected test view: deps %v, err %v", tv.Deps(), tv.Err())
+	}
+	tv.Deps()[0] = 42
+	if tv.Deps()[0] != build.Id() {
+		t.Error("Deps should return a copy")
+	}
+	if !reflect.DeepEqual(lines, []string{"build: built"}) {
+		t.Errorf("unexpected output lines %v", lines)
+	}
+}
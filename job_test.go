@@ -7,8 +7,11 @@ SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
 package jobExecutor
 
 import (
+	"bytes"
 	"os/exec"
+	"sync"
 	"testing"
+	"time"
 )
 
 func Test_job_run(t *testing.T) {
@@ -18,7 +21,7 @@ func Test_job_run(t *testing.T) {
 	if !j.IsState(JobStatePending) {
 		t.Fatalf("Job not marked as Pending")
 	}
-	j.run(func() { doneCalled = true })
+	j.run(nil, func() { doneCalled = true })
 	if !doneCalled {
 		t.Fatalf("run did not call done")
 	}
@@ -105,15 +108,105 @@ func Test_job_Templates(t *testing.T) {
 	testJobList := JobList{testjob}
 
 	for _, tplName := range jobTemplates {
-		out := testjob.execTemplate(outputTemplate.Lookup(tplName))
+		out, _ := tplExec(outputTemplate.Lookup(tplName), testjob)
 		if out == "" || out == "undefined" {
 			t.Fatalf(`Empty job template %s`, tplName)
 		}
 	}
 	for _, tplName := range jobListTemplates {
-		out := testJobList.execTemplate(outputTemplate.Lookup(tplName))
+		out, _ := tplExec(outputTemplate.Lookup(tplName), testJobList)
 		if out == "" || out == "undefined" {
 			t.Fatalf(`Empty JobList template %s`, tplName)
 		}
 	}
 }
+
+func Test_job_cancel(t *testing.T) {
+	j := &job{Cmd: exec.Command("sh", "-c", "echo started; exec sleep 10")}
+	j.start(0)
+	started := make(chan struct{})
+	done := make(chan struct{})
+	var once sync.Once
+	go j.run(func(line string) { once.Do(func() { close(started) }) }, func() { close(done) })
+	select {
+	case <-started: // the process is running
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not start")
+	}
+	j.cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled job did not terminate")
+	}
+	if !j.IsState(JobStateFailed) || j.Err != ErrJobCanceled {
+		t.Fatalf("canceled job should fail with ErrJobCanceled, got %v", j.Err)
+	}
+	j.reset()
+	if !j.IsState(JobStatePending) || j.Err != nil || j.Cmd.Process != nil || j.killed {
+		t.Fatal("reset job should be pending with a fresh command")
+	}
+
+	// the process terminated successfully before the kill
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	j = &job{Cmd: cmd, canceled: true, killed: true}
+	if j.killedByCancel() {
+		t.Error("a command that succeeded should not be reported as canceled")
+	}
+}
+
+// func types are not comparable
+type funcWriter func(p []byte) (int, error)
+
+func (w funcWriter) Write(p []byte) (int, error) { return w(p) }
+
+func Test_job_runNonComparableWriters(t *testing.T) {
+	var mutex sync.Mutex
+	var out, lines []string
+	w := funcWriter(func(p []byte) (int, error) {
+		mutex.Lock()
+		out = append(out, string(p))
+		mutex.Unlock()
+		return len(p), nil
+	})
+	cmd := exec.Command("sh", "-c", "echo out; echo err >&2")
+	cmd.Stdout, cmd.Stderr = w, funcWriter(w)
+	j := &job{Cmd: cmd}
+	j.run(func(line string) { lines = append(lines, line) }, func() {})
+	if j.Err != nil || len(lines) != 2 || len(out) == 0 {
+		t.Errorf("unexpected result err: %v, lines: %v, out: %v", j.Err, lines, out)
+	}
+	if !sameWriter(nil, nil) || sameWriter(w, nil) || sameWriter(w, w) {
+		t.Errorf("sameWriter() returned unexpected results")
+	}
+	var buf bytes.Buffer
+	if !sameWriter(&buf, &buf) || sameWriter(&buf, &bytes.Buffer{}) {
+		t.Errorf("sameWriter() should compare comparable writers")
+	}
+}
+
+func Test_job_start(t *testing.T) {
+	dep := &job{status: JobStateDone | JobStateFailed}
+	var runCalled bool
+	j := &job{Fn: func() (string, error) { runCalled = true; return "", nil }, DependsOn: []*job{dep}}
+	if j.start(2) || j.Err != ErrRequiredJobFailed || j.stateName() != "failed" || j.attempts != 0 || j.slot != 2 {
+		t.Errorf("skipped job should be done by start and not count as an attempt, got %v %q %d", j.Err, j.stateName(), j.attempts)
+	}
+	if j.StartTime.IsZero() || j.Duration != j.EndTime.Sub(j.StartTime) {
+		t.Errorf("skipped job should have consistent times")
+	}
+	doneCalled := false
+	j.run(nil, func() { doneCalled = true })
+	if runCalled || !doneCalled || j.Err != ErrRequiredJobFailed {
+		t.Errorf("run should only call done for skipped jobs")
+	}
+
+	j = &job{Fn: func() (string, error) { return "", nil }}
+	before := time.Now()
+	if !j.start(0) || j.stateName() != "running" || j.attempts != 1 || j.StartTime.Before(before) {
+		t.Errorf("job should be marked running by start, got %q", j.stateName())
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	JSONEventExecutorStart = "executorStart"
+	JSONEventJobStart      = "jobStart"
+	JSONEventOutput        = "output"
+	JSONEventJobDone       = "jobDone"
+	JSONEventExecutorDone  = "executorDone"
+)
+
+// JSONEvent is the object emitted for each event by WithJSONOutput
+type JSONEvent struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	// job related fields, unset for executor events
+	JobId   *int   `json:"jobId,omitempty"`
+	JobName string `json:"jobName,omitempty"`
+	Line    string `json:"line,omitempty"`
+	State   string `json:"state,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// elapsed time in seconds for jobDone and executorDone events
+	Elapsed float64 `json:"elapsed,omitempty"`
+	// executor related fields
+	Jobs      []JSONEventJob `json:"jobs,omitempty"`
+	Succeeded int            `json:"succeeded,omitempty"`
+	Failed    int            `json:"failed,omitempty"`
+}
+
+type JSONEventJob struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name"`
+	DependsOn []int  `json:"dependsOn,omitempty"`
+}
+
+// Write events as newline delimited JSON objects (one JSONEvent per line) to w.
+// It only relies on events handlers so it can be combined with other
+// With*Output methods.
+func (e *JobExecutor) WithJSONOutput(w io.Writer) *JobExecutor {
+	var mutex sync.Mutex
+	encoder := json.NewEncoder(w)
+	emit := func(event JSONEvent) {
+		mutex.Lock()
+		encoder.Encode(event)
+		mutex.Unlock()
+	}
+	jobEvent := func(eventType string, j *job) JSONEvent {
+		id := j.id
+		return JSONEvent{Event: eventType, Time: time.Now(), JobId: &id, JobName: j.Name()}
+	}
+	var startTime time.Time
+	e.onJobsStart(func(jobs JobList) {
+		startTime = time.Now()
+		event := JSONEvent{Event: JSONEventExecutorStart, Time: startTime, Jobs: make([]JSONEventJob, len(jobs))}
+		for i, j := range jobs {
+			event.Jobs[i] = JSONEventJob{Id: j.id, Name: j.Name()}
+			for _, dep := range j.DependsOn {
+				event.Jobs[i].DependsOn = append(event.Jobs[i].DependsOn, dep.id)
+			}
+		}
+		emit(event)
+	})
+	e.onJobStart(func(jobs JobList, jobId int) {
+		event := jobEvent(JSONEventJobStart, jobs[jobId])
+		event.State = jobs[jobId].stateName()
+		emit(event)
+	})
+	e.onJobOutput(func(jobs JobList, jobId int, line string) {
+		event := jobEvent(JSONEventOutput, jobs[jobId])
+		event.Line = line
+		emit(event)
+	})
+	e.onJobDone(func(jobs JobList, jobId int) {
+		j := jobs[jobId]
+		event := jobEvent(JSONEventJobDone, j)
+		event.State = j.stateName()
+		j.mutex.RLock()
+		event.Elapsed = j.Duration.Seconds()
+		if j.Err != nil {
+			event.Error = j.Err.Error()
+		}
+		j.mutex.RUnlock()
+		emit(event)
+	})
+	e.onJobsDone(func(jobs JobList) {
+		event := JSONEvent{Event: JSONEventExecutorDone, Time: time.Now(), Elapsed: time.Since(startTime).Seconds()}
+		for _, j := range jobs {
+			if j.IsState(JobStateSucceed) {
+				event.Succeeded++
+			} else if j.IsState(JobStateFailed) {
+				event.Failed++
+			}
+		}
+		emit(event)
+	})
+	return e
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"testing"
+)
+
+func TestJobExecutor_WithJSONOutput(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewExecutor().WithJSONOutput(&buf)
+	e.AddNamedJobCmd("echo", exec.Command("printf", "line1\nline2\n"))
+	e.AddNamedJobFn("fail", TestRunnableFailFn)
+	e.Execute()
+
+	counts := map[string]int{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var event JSONEvent
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("invalid json output: %v", err)
+		}
+		counts[event.Event]++
+		switch event.Event {
+		case JSONEventExecutorStart:
+			if len(event.Jobs) != 2 {
+				t.Errorf("executorStart should list 2 jobs got %d", len(event.Jobs))
+			}
+		case JSONEventJobDone:
+			if event.JobName == "fail" && (event.State != "failed" || event.Error != "test error") {
+				t.Errorf("unexpected jobDone event for failing job: %+v", event)
+			}
+		case JSONEventExecutorDone:
+			if event.Succeeded != 1 || event.Failed != 1 {
+				t.Errorf("unexpected executorDone counts: %+v", event)
+			}
+		}
+	}
+	want := map[string]int{
+		JSONEventExecutorStart: 1,
+		JSONEventJobStart:      2,
+		JSONEventOutput:        2,
+		JSONEventJobDone:       2,
+		JSONEventExecutorDone:  1,
+	}
+	for eventType, count := range want {
+		if counts[eventType] != count {
+			t.Errorf("expected %d %s events got %d", count, eventType, counts[eventType])
+		}
+	}
+}
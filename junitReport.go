@@ -0,0 +1,134 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Content string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// Write a JUnit XML report of the jobs to w.
+// Each job is reported as a testcase, jobs that didn't run because of a failed
+// dependency (or not run at all) are reported as skipped.
+func (e *JobExecutor) WriteJUnitReport(w io.Writer) error {
+	suite := junitTestSuite{Name: "jobExecutor", Tests: e.Len()}
+	var start, end time.Time
+	for _, j := range e.jobs {
+		j.mutex.RLock()
+		testCase := junitTestCase{
+			Name:      j.Name(),
+			ClassName: suite.Name,
+			Time:      junitSeconds(j.Duration),
+			SystemOut: j.Res,
+		}
+		if !j.StartTime.IsZero() {
+			if start.IsZero() || j.StartTime.Before(start) {
+				start = j.StartTime
+			}
+			if jobEnd := j.StartTime.Add(j.Duration); jobEnd.After(end) {
+				end = jobEnd
+			}
+		}
+		switch {
+		case j.status&JobStateDone == 0:
+			testCase.Skipped = &junitMessage{Message: "job was not run"}
+			suite.Skipped++
+		case errors.Is(j.Err, ErrRequiredJobFailed):
+			testCase.Skipped = &junitMessage{Message: j.Err.Error()}
+			suite.Skipped++
+		case j.Err != nil:
+			testCase.Failure = &junitMessage{Message: j.Err.Error(), Type: fmt.Sprintf("%T", j.Err), Content: j.Err.Error()}
+			suite.Failures++
+		}
+		j.mutex.RUnlock()
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Time = junitSeconds(end.Sub(start))
+	if !start.IsZero() {
+		suite.Timestamp = start.Format("2006-01-02T15:04:05")
+	}
+	report := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Write a JUnit XML report to filename when all jobs are done
+// (see WriteJUnitReport), errors are written to the executor error output
+func (e *JobExecutor) WithJUnitReport(filename string) *JobExecutor {
+	e.onJobsDone(func(jobs JobList) {
+		f, err := os.Create(filename)
+		if err == nil {
+			err = e.WriteJUnitReport(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(e.getErrOutput(), "can't write junit report:", err)
+		}
+	})
+	return e
+}
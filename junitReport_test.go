@@ -0,0 +1,49 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJobExecutor_WithJUnitReport(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "junit.xml")
+	e := NewExecutor().WithJUnitReport(filename)
+	jobs := e.AddJobs(
+		NamedJob{"success", TestRunnableSuccessFn},
+		NamedJob{"fail", TestRunnableFailFn},
+		NamedJob{"skipped", TestRunnableSuccessFn},
+	)
+	e.AddJobDependency(jobs[2], jobs[1])
+	e.DagExecute()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(content, &report); err != nil {
+		t.Fatalf("invalid xml report: %v", err)
+	}
+	if report.Tests != 3 || report.Failures != 1 || report.Skipped != 1 {
+		t.Fatalf("unexpected report counts: tests %d, failures %d, skipped %d", report.Tests, report.Failures, report.Skipped)
+	}
+	cases := report.Suites[0].TestCases
+	if cases[0].Name != "success" || cases[0].SystemOut != "done" || cases[0].Failure != nil {
+		t.Errorf("unexpected testcase for succeeding job: %+v", cases[0])
+	}
+	if cases[1].Failure == nil || cases[1].Failure.Message != "test error" {
+		t.Errorf("failing job should be reported as failure: %+v", cases[1])
+	}
+	if cases[2].Skipped == nil {
+		t.Errorf("job with failed dependency should be reported as skipped: %+v", cases[2])
+	}
+}
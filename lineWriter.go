@@ -0,0 +1,67 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"sync"
+	"unicode/utf8"
+)
+
+// lineWriter calls onLine for each complete line written to it (without the
+// trailing new line). Call Flush to get the remaining partial line if any.
+// it is safe for concurrent use
+type lineWriter struct {
+	mutex  sync.Mutex
+	buf    []byte
+	onLine func(line string)
+	// when greater than 0, longer lines are split in lines of maxLength bytes
+	maxLength int
+}
+
+func newLineWriter(onLine func(line string)) *lineWriter {
+	return &lineWriter{onLine: onLine}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 || (w.maxLength > 0 && i > w.maxLength) {
+			if w.maxLength > 0 && len(w.buf) > w.maxLength {
+				// don't split a multi-byte character
+				cut := w.maxLength
+				for cut > 0 && !utf8.RuneStart(w.buf[cut]) {
+					cut--
+				}
+				if cut == 0 {
+					cut = w.maxLength
+				}
+				w.onLine(string(w.buf[:cut]))
+				w.buf = w.buf[cut:]
+				continue
+			}
+			break
+		}
+		w.onLine(string(bytes.TrimSuffix(w.buf[:i], []byte{'\r'})))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// send remaining partial line if any
+func (w *lineWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.buf) > 0 {
+		w.onLine(string(w.buf))
+		w.buf = nil
+	}
+}
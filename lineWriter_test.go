@@ -0,0 +1,39 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{"Should send complete lines", []string{"line1\nline2\n"}, []string{"line1", "line2"}},
+		{"Should join partial writes", []string{"li", "ne1\nli", "ne2\n"}, []string{"line1", "line2"}},
+		{"Should send remaining line on flush", []string{"line1\nline2"}, []string{"line1", "line2"}},
+		{"Should trim carriage return", []string{"line1\r\n"}, []string{"line1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			lw := newLineWriter(func(line string) { got = append(got, line) })
+			for _, w := range tt.writes {
+				lw.Write([]byte(w))
+			}
+			lw.Flush()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
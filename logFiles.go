@@ -0,0 +1,222 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// run directories are named after the run start time so they sort chronologically
+const logRunIdLayout = "20060102-150405.000"
+const logTimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// name of the run summary file written in each run directory
+const LogSummaryFileName = "summary.log"
+
+var logFileNameReplacer = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// return a file name (without extension) for the given job name
+func logFileName(name string) string {
+	name = strings.Trim(logFileNameReplacer.ReplaceAllString(name, "_"), "_.")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	if name == "" {
+		name = "job"
+	}
+	return name
+}
+
+// remove the oldest run directories of dir to keep only the keepRuns most recent ones
+func pruneLogRuns(dir string, keepRuns int) error {
+	if keepRuns <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var runs []string // sorted by name, so chronologically
+	for _, entry := range entries {
+		if _, err := time.Parse(logRunIdLayout, entry.Name()); entry.IsDir() && err == nil {
+			runs = append(runs, entry.Name())
+		}
+	}
+	for i := 0; i < len(runs)-keepRuns; i++ {
+		if err := os.RemoveAll(filepath.Join(dir, runs[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write a summary of the run: one line per job with its state, duration,
+// exit code, log file and error
+func writeLogSummary(path string, runId string, jobs JobList) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(f, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "run %s: %d jobs\n", runId, len(jobs))
+	fmt.Fprintln(w, "STATE\tDURATION\tEXIT\tJOB\tLOG\tERROR")
+	for _, j := range jobs {
+		state, exitCode := j.stateName(), j.exitCode()
+		j.mutex.RLock()
+		exit, errMsg := "-", ""
+		if exitCode >= 0 {
+			exit = fmt.Sprint(exitCode)
+		}
+		if j.Err != nil {
+			errMsg = j.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", state, j.Duration.Round(time.Millisecond), exit, j.Name(), filepath.Base(j.logFile), errMsg)
+		j.mutex.RUnlock()
+	}
+	err = w.Flush()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Write the full output of each job, with a timestamp on each line, to
+// <dir>/<run-id>/<job-name>.log and a summary of the run to
+// <dir>/<run-id>/summary.log. run-id is the start time of the run.
+// When keepRuns is greater than 0 only the keepRuns most recent run
+// directories are kept. Errors are written to the executor error output.
+// See Job.LogFile to get the path of a job log file and WithTailOutput to
+// only display the end of jobs outputs.
+func (e *JobExecutor) WithLogFiles(dir string, keepRuns int) *JobExecutor {
+	var mutex sync.Mutex
+	var runId, runDir string
+	files := make(map[int]*os.File)
+	usedNames := make(map[string]bool)
+	reportErr := func(err error) {
+		fmt.Fprintln(e.getErrOutput(), "can't write job logs:", err)
+	}
+	e.onJobsStart(func(jobs JobList) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		runId = time.Now().Format(logRunIdLayout)
+		runDir = filepath.Join(dir, runId)
+		usedNames = make(map[string]bool)
+		if err := os.MkdirAll(runDir, 0o755); err != nil {
+			reportErr(err)
+			runDir = ""
+			return
+		}
+		if err := pruneLogRuns(dir, keepRuns); err != nil {
+			reportErr(err)
+		}
+	})
+	e.onJobStart(func(jobs JobList, jobId int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if runDir == "" {
+			return
+		}
+		j := jobs[jobId]
+		name := logFileName(j.Name())
+		if usedNames[name] || name+".log" == LogSummaryFileName {
+			name = fmt.Sprintf("%s-%d", name, jobId)
+		}
+		usedNames[name] = true
+		path := filepath.Join(runDir, name+".log")
+		f, err := os.Create(path)
+		if err != nil {
+			reportErr(err)
+			return
+		}
+		files[jobId] = f
+		j.mutex.Lock()
+		j.logFile = path
+		j.mutex.Unlock()
+		fmt.Fprintf(f, "%s job started: %s\n", time.Now().Format(logTimestampLayout), j.Name())
+	})
+	e.onJobOutput(func(jobs JobList, jobId int, line string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if f, ok := files[jobId]; ok {
+			fmt.Fprintf(f, "%s %s\n", time.Now().Format(logTimestampLayout), line)
+		}
+	})
+	e.onJobDone(func(jobs JobList, jobId int) {
+		mutex.Lock()
+		f, ok := files[jobId]
+		delete(files, jobId)
+		mutex.Unlock()
+		if !ok {
+			return
+		}
+		j := jobs[jobId]
+		status := j.stateName()
+		if err := (&Job{j}).Err(); err != nil {
+			status += ": " + err.Error()
+		}
+		fmt.Fprintf(f, "%s job %s\n", time.Now().Format(logTimestampLayout), status)
+		if err := f.Close(); err != nil {
+			reportErr(err)
+		}
+	})
+	e.onJobsDone(func(jobs JobList) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if runDir == "" {
+			return
+		}
+		if err := writeLogSummary(filepath.Join(runDir, LogSummaryFileName), runId, jobs); err != nil {
+			reportErr(err)
+		}
+	})
+	return e
+}
+
+// return the last n lines of s and the number of lines that were left out
+func tailLines(s string, n int) (string, int) {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if s == "" || n >= len(lines) {
+		return strings.TrimRight(s, "\n"), 0
+	}
+	if n < 0 {
+		n = 0
+	}
+	return strings.Join(lines[len(lines)-n:], "\n"), len(lines) - n
+}
+
+// TailReport is passed to the "tailReport" template used by WithTailOutput
+type TailReport struct {
+	Jobs []JobView
+	// number of output lines to display for each job
+	Lines int
+}
+
+// return the number of lines of the job output left out of the report
+func (r TailReport) Skipped(j JobView) int {
+	_, skipped := tailLines(j.Output(), r.Lines)
+	return skipped
+}
+
+// Display a report when all jobs are done with the status of each job and only
+// the last lines of its output, followed by the path of its full log file
+// when used with WithLogFiles. The report is rendered with the "tailReport"
+// template which receives a TailReport.
+func (e *JobExecutor) WithTailOutput(lines int) *JobExecutor {
+	e.onJobsDone(func(jobs JobList) {
+		report := TailReport{Jobs: jobs.views(), Lines: lines}
+		fmt.Fprint(e.getOutput(), e.execTemplate("tailReport", report))
+	})
+	return e
+}
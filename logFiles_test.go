@@ -0,0 +1,176 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_logFileName(t *testing.T) {
+	tests := map[string]string{
+		"build":                "build",
+		"go test ./...":        "go_test",
+		"/usr/bin/ls -l":       "usr_bin_ls_-l",
+		"summary":              "summary",
+		"   ":                  "job",
+		"release-1.2_linux.64": "release-1.2_linux.64",
+	}
+	for name, want := range tests {
+		if got := logFileName(name); got != want {
+			t.Errorf("logFileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func Test_tailLines(t *testing.T) {
+	tests := []struct {
+		s           string
+		n           int
+		want        string
+		wantSkipped int
+	}{
+		{"", 2, "", 0},
+		{"a\nb\n", 2, "a\nb", 0},
+		{"a\nb\nc\nd\n", 2, "c\nd", 2},
+		{"a\nb", 0, "", 2},
+	}
+	for _, tt := range tests {
+		got, skipped := tailLines(tt.s, tt.n)
+		if got != tt.want || skipped != tt.wantSkipped {
+			t.Errorf("tailLines(%q, %d) = %q, %d, want %q, %d", tt.s, tt.n, got, skipped, tt.want, tt.wantSkipped)
+		}
+	}
+}
+
+func TestJobExecutor_WithLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	// old runs to prune and a directory which is not a run
+	for _, name := range []string{"20200101-000000.000", "20200102-000000.000", "keep-me"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out, errOut bytes.Buffer
+	e := NewExecutor().SetOutput(&out).SetErrorOutput(&errOut).WithLogFiles(dir, 2).WithTailOutput(1)
+	echo := e.AddJob(NamedJob{"echo", exec.Command("sh", "-c", "echo line1; echo line2 >&2; echo line3")})
+	fail := e.AddJob(NamedJob{"fail", func() (string, error) { return "", errors.New("test error 2") }})
+	// computed name collides with the named job above
+	dup := e.AddJob(exec.Command("echo"))
+	skipped := e.AddJob(NamedJob{"skipped", TestRunnableSuccessFn})
+	e.AddJobDependency(skipped, fail)
+	e.DagExecute()
+	if errOut.Len() != 0 {
+		t.Fatalf("unexpected error output: %s", errOut.String())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 3 || names[0] != "20200102-000000.000" || names[2] != "keep-me" {
+		t.Fatalf("old runs should be pruned, got %v", names)
+	}
+	runDir := filepath.Join(dir, names[1])
+
+	if got := echo.LogFile(); got != filepath.Join(runDir, "echo.log") {
+		t.Errorf("unexpected log file %q", got)
+	}
+	if got := fail.LogFile(); got != filepath.Join(runDir, "fail.log") {
+		t.Errorf("unexpected log file %q", got)
+	}
+	if got := dup.LogFile(); got != filepath.Join(runDir, "echo-2.log") {
+		t.Errorf("duplicated names should get a distinct log file, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(runDir, "skipped.log")); skipped.LogFile() != "" || err == nil {
+		t.Errorf("jobs skipped by a failed dependency should not get a log file")
+	}
+	content, err := os.ReadFile(echo.LogFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	timestamped := regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}\S* `)
+	want := []string{"job started: echo", "line1", "line2", "line3", "job succeed"}
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected log content:\n%s", content)
+	}
+	for i, line := range lines {
+		if !timestamped.MatchString(line) || !strings.HasSuffix(line, " "+want[i]) {
+			t.Errorf("line %d should be timestamped %q, got %q", i, want[i], line)
+		}
+	}
+	if content, _ := os.ReadFile(fail.LogFile()); !strings.Contains(string(content), "job failed: test error 2") {
+		t.Errorf("failed job log should contain the error:\n%s", content)
+	}
+
+	summary, err := os.ReadFile(filepath.Join(runDir, LogSummaryFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"run " + names[1] + ": 4 jobs", "succeed", "echo.log", "failed", "fail.log", "echo-2.log", "test error 2"} {
+		if !strings.Contains(string(summary), want) {
+			t.Errorf("summary should contain %q:\n%s", want, summary)
+		}
+	}
+
+	report := out.String()
+	for _, want := range []string{"4 jobs terminated:\n", "  … 2 more lines\n  line3\n  full output: " + echo.LogFile(), "  error: test error 2\n  full output: " + fail.LogFile()} {
+		if !strings.Contains(report, want) {
+			t.Errorf("tail report should contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "line1") {
+		t.Errorf("tail report should only contain the last line:\n%s", report)
+	}
+}
+
+func Test_pruneLogRuns(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		os.Mkdir(filepath.Join(dir, time.Date(2020, 1, i+1, 0, 0, 0, 0, time.UTC).Format(logRunIdLayout)), 0o755)
+	}
+	if err := pruneLogRuns(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("keepRuns 0 should not prune anything")
+	}
+	if err := pruneLogRuns(dir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "20200103-000000.000" {
+		t.Errorf("only the most recent run should be kept, got %v", entries)
+	}
+}
+
+func TestJobExecutor_WithTailOutputTemplate(t *testing.T) {
+	var out bytes.Buffer
+	e := NewExecutor().SetOutput(&out).WithTailOutput(1)
+	e.AddJob(NamedJob{"echo", func() (string, error) { return "line1\nline2\n", nil }})
+	tpl := `{{define "tailReport"}}{{range .Jobs}}{{.Name}} ({{$.Skipped .}} skipped): {{tail $.Lines .Res}}{{end}}{{end}}`
+	for _, name := range RequiredTemplates {
+		tpl += `{{define "` + name + `"}}{{end}}`
+	}
+	err := e.SetTemplateString(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Execute()
+	if got := out.String(); got != "echo (1 skipped): line2" {
+		t.Errorf("tailReport template should be used, got %q", got)
+	}
+}
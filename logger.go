@@ -0,0 +1,67 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"log/slog"
+	"time"
+)
+
+// return job attributes grouped under a "job" key
+func jobLogAttr(j *job, attrs ...any) slog.Attr {
+	return slog.Group("job", append([]any{"id", j.id, "name", j.Name()}, attrs...)...)
+}
+
+// Log jobs lifecycle events to logger with structured attributes.
+// Failed jobs are logged at error level, other events at info level.
+// If logOutput is true each line of job output is logged too as a "job output"
+// message with the line in a "line" attribute.
+func (e *JobExecutor) WithLogger(logger *slog.Logger, logOutput bool) *JobExecutor {
+	var startTime time.Time
+	e.onJobsStart(func(jobs JobList) {
+		startTime = time.Now()
+		logger.Info("jobs started", "jobs", len(jobs))
+	})
+	e.onJobStart(func(jobs JobList, jobId int) {
+		j := jobs[jobId]
+		logger.Info("job started", jobLogAttr(j, "state", j.stateName()))
+	})
+	e.onJobDone(func(jobs JobList, jobId int) {
+		j := jobs[jobId]
+		attrs := []any{"state", j.stateName()}
+		if exitCode := j.exitCode(); exitCode >= 0 {
+			attrs = append(attrs, "exit_code", exitCode)
+		}
+		j.mutex.RLock()
+		attrs = append(attrs, "duration", j.Duration)
+		err := j.Err
+		j.mutex.RUnlock()
+		if err != nil {
+			logger.Error("job failed", jobLogAttr(j, attrs...), "error", err)
+		} else {
+			logger.Info("job succeeded", jobLogAttr(j, attrs...))
+		}
+	})
+	if logOutput {
+		e.onJobOutput(func(jobs JobList, jobId int, line string) {
+			logger.Info("job output", jobLogAttr(jobs[jobId]), slog.String("line", line))
+		})
+	}
+	e.onJobsDone(func(jobs JobList) {
+		succeeded, failed := 0, 0
+		for _, j := range jobs {
+			if j.IsState(JobStateSucceed) {
+				succeeded++
+			} else if j.IsState(JobStateFailed) {
+				failed++
+			}
+		}
+		logger.Info("jobs done", "succeeded", succeeded, "failed", failed, "duration", time.Since(startTime))
+	})
+	return e
+}
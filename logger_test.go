@@ -0,0 +1,57 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestJobExecutor_WithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	NewExecutor().
+		WithLogger(logger, true).
+		AddNamedJobFn("success", TestRunnableSuccessFn).
+		AddNamedJobFn("fail", TestRunnableFailFn).
+		Execute()
+
+	type record struct {
+		Level string
+		Msg   string
+		Line  string
+		Error string
+		Job   struct {
+			Id    int
+			Name  string
+			State string
+		}
+	}
+	msgs := map[string]record{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var r record
+		if err := decoder.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		msgs[r.Msg] = r
+	}
+	for _, msg := range []string{"jobs started", "job started", "job succeeded", "job failed", "job output", "jobs done"} {
+		if _, ok := msgs[msg]; !ok {
+			t.Errorf("missing %q log record", msg)
+		}
+	}
+	if r := msgs["job failed"]; r.Level != "ERROR" || r.Error != "test error" || r.Job.Name != "fail" || r.Job.State != "failed" {
+		t.Errorf("unexpected failed job record %+v", r)
+	}
+	if r := msgs["job output"]; r.Line != "done" || r.Job.Name != "success" {
+		t.Errorf("output line should have job attributes %+v", r)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidMatrix = fmt.Errorf("invalid matrix")
+
+// Matrix maps parameter names to the values a job is expanded over,
+// see AddMatrixJobs
+type Matrix map[string][]string
+
+// MatrixParams are the parameters of one expanded job
+type MatrixParams map[string]string
+
+// MatrixOptions customize the expansion of a Matrix
+type MatrixOptions struct {
+	// combinations to add to the expansion, they may set parameters not in the
+	// matrix and are not subject to Exclude rules
+	Include []MatrixParams
+	// combinations to remove from the expansion, a combination is excluded when
+	// it matches all the parameters of a rule
+	Exclude []MatrixParams
+	// name of a job depending on all expanded jobs, none is added if empty
+	FanIn string
+	// return the name of an expanded job, default to "name (key=value, ...)"
+	Name func(name string, params MatrixParams) string
+}
+
+// check params contains all the values of rule
+func (p MatrixParams) matches(rule MatrixParams) bool {
+	for k, v := range rule {
+		if value, ok := p[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// return parameter names in alphabetical order
+func (p MatrixParams) keys() []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// return the parameters as environment variables, names are upper cased and
+// characters other than letters, digits and underscores are replaced by "_"
+func (p MatrixParams) environ() []string {
+	env := make([]string, 0, len(p))
+	for _, k := range p.keys() {
+		name := strings.Map(func(r rune) rune {
+			if r == '_' || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return '_'
+		}, strings.ToUpper(k))
+		env = append(env, name+"="+p[k])
+	}
+	return env
+}
+
+func defaultMatrixJobName(name string, params MatrixParams) string {
+	pairs := make([]string, 0, len(params))
+	for _, k := range params.keys() {
+		pairs = append(pairs, k+"="+params[k])
+	}
+	return name + " (" + strings.Join(pairs, ", ") + ")"
+}
+
+// return all combinations of the matrix in a stable order (parameters sorted
+// by name, the last one varying first), without excluded ones and followed by
+// included ones
+func (m Matrix) expand(opts MatrixOptions) []MatrixParams {
+	keys := MatrixParams{}
+	for k := range m {
+		keys[k] = ""
+	}
+	combinations := []MatrixParams{{}}
+	for _, k := range keys.keys() {
+		next := make([]MatrixParams, 0, len(combinations)*len(m[k]))
+		for _, c := range combinations {
+			for _, v := range m[k] {
+				params := make(MatrixParams, len(c)+1)
+				for ck, cv := range c {
+					params[ck] = cv
+				}
+				params[k] = v
+				next = append(next, params)
+			}
+		}
+		combinations = next
+	}
+	if len(m) == 0 {
+		combinations = nil
+	}
+	res := make([]MatrixParams, 0, len(combinations)+len(opts.Include))
+combinationsLoop:
+	for _, c := range combinations {
+		for _, rule := range opts.Exclude {
+			if c.matches(rule) {
+				continue combinationsLoop
+			}
+		}
+		res = append(res, c)
+	}
+	return append(res, opts.Include...)
+}
+
+// return a job for the given params from a matrix job template
+func matrixJob(tpl interface{}, params MatrixParams) (interface{}, error) {
+	switch typed := tpl.(type) {
+	case *exec.Cmd:
+		cmd := cloneCmd(typed)
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(append([]string{}, cmd.Env...), params.environ()...)
+		return cmd, nil
+	case func(MatrixParams) *exec.Cmd:
+		if cmd := typed(params); cmd != nil {
+			return cmd, nil
+		}
+		return nil, fmt.Errorf("%w: no command returned for %v", ErrInvalidMatrix, params)
+	case func(MatrixParams) (string, error):
+		return func() (string, error) { return typed(params) }, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported job type %T", ErrInvalidMatrix, tpl)
+}
+
+// Add one job per combination of the matrix parameters and return them.
+// The job template can be:
+//   - an *exec.Cmd, it is cloned for each combination with parameters added to
+//     its environment (upper cased, "goos" becomes GOOS)
+//   - a func(MatrixParams) *exec.Cmd returning the command for a combination
+//   - a func(MatrixParams) (string, error) called with the combination params
+//
+// Expanded jobs are named "name (key=value, ...)" unless opts.Name is set and
+// names must be unique (see GetJob). Nothing is added if an error occurs.
+//
+//	jobs, err := executor.AddMatrixJobs("build", exec.Command("go", "build", "./..."),
+//		jobExecutor.Matrix{"goos": {"linux", "darwin"}, "goarch": {"amd64", "arm64"}},
+//		jobExecutor.MatrixOptions{
+//			Exclude: []jobExecutor.MatrixParams{{"goos": "darwin", "goarch": "amd64"}},
+//			FanIn:   "build-all",
+//		})
+func (e *JobExecutor) AddMatrixJobs(name string, job interface{}, matrix Matrix, opts MatrixOptions) ([]Job, error) {
+	if opts.Name == nil {
+		opts.Name = defaultMatrixJobName
+	}
+	combinations := matrix.expand(opts)
+	if len(combinations) == 0 {
+		return nil, fmt.Errorf("%w: %q expands to no job", ErrInvalidMatrix, name)
+	}
+	names := make([]string, len(combinations))
+	jobs := make([]interface{}, len(combinations))
+	seen := map[string]bool{opts.FanIn: opts.FanIn != ""}
+	for i, params := range combinations {
+		names[i] = opts.Name(name, params)
+		if _, ok := e.GetJob(names[i]); ok || seen[names[i]] {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateJobName, names[i])
+		}
+		seen[names[i]] = true
+		var err error
+		if jobs[i], err = matrixJob(job, params); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := e.GetJob(opts.FanIn); ok && opts.FanIn != "" {
+		return nil, fmt.Errorf("%w: %q", ErrDuplicateJobName, opts.FanIn)
+	}
+	res := make([]Job, len(jobs))
+	for i, j := range jobs {
+		res[i] = e.AddJob(NamedJob{Name: names[i], Job: j})
+	}
+	if opts.FanIn != "" {
+		fanIn := e.AddJob(NamedJob{Name: opts.FanIn, Job: func() (string, error) { return "", nil }})
+		for _, j := range res {
+			e.AddJobDependency(fanIn, j)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMatrix_expand(t *testing.T) {
+	matrix := Matrix{"goos": {"linux", "darwin"}, "goarch": {"amd64", "arm64"}}
+	got := matrix.expand(MatrixOptions{
+		Exclude: []MatrixParams{{"goos": "darwin", "goarch": "amd64"}},
+		Include: []MatrixParams{{"goos": "windows", "goarch": "amd64", "ext": ".exe"}},
+	})
+	want := []MatrixParams{
+		{"goarch": "amd64", "goos": "linux"},
+		{"goarch": "arm64", "goos": "linux"},
+		{"goarch": "arm64", "goos": "darwin"},
+		{"goos": "windows", "goarch": "amd64", "ext": ".exe"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expand() = %v, want %v", got, want)
+	}
+	if got := (Matrix{}).expand(MatrixOptions{}); len(got) != 0 {
+		t.Errorf("empty matrix should expand to nothing, got %v", got)
+	}
+}
+
+func TestMatrixParams_environ(t *testing.T) {
+	got := MatrixParams{"goos": "linux", "go-version": "1.21"}.environ()
+	want := []string{"GO_VERSION=1.21", "GOOS=linux"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("environ() = %v, want %v", got, want)
+	}
+}
+
+func TestJobExecutor_AddMatrixJobs(t *testing.T) {
+	e := NewExecutor()
+	jobs, err := e.AddMatrixJobs("build", exec.Command("sh", "-c", "echo $GOOS/$GOARCH"),
+		Matrix{"goos": {"linux", "darwin"}, "goarch": {"amd64"}},
+		MatrixOptions{FanIn: "build-all"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].Name() != "build (goarch=amd64, goos=linux)" {
+		t.Fatalf("unexpected jobs %v", jobs)
+	}
+	fanIn, ok := e.GetJob("build-all")
+	if !ok || len(fanIn.job.DependsOn) != 2 {
+		t.Fatalf("fan-in job should depend on all expanded jobs")
+	}
+	if errs := e.DagExecute(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if got := jobs[1].CombinedOutput(); got != "darwin/amd64\n" {
+		t.Errorf("parameters should be set as env vars, got %q", got)
+	}
+
+	var mutex sync.Mutex
+	var called []string
+	e = NewExecutor()
+	_, err = e.AddMatrixJobs("test", func(params MatrixParams) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		called = append(called, params["pkg"])
+		return "", nil
+	}, Matrix{"pkg": {"a", "b"}}, MatrixOptions{
+		Name: func(name string, params MatrixParams) string { return name + "-" + params["pkg"] },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.GetJob("test-b"); !ok || e.Len() != 2 {
+		t.Errorf("jobs should be named with opts.Name")
+	}
+	e.Execute()
+	if strings.Join(called, "") != "ab" && strings.Join(called, "") != "ba" {
+		t.Errorf("fn should be called with params, got %v", called)
+	}
+
+	_, err = e.AddMatrixJobs("test", func(params MatrixParams) *exec.Cmd { return exec.Command("true") },
+		Matrix{"pkg": {"b", "c"}}, MatrixOptions{
+			Name: func(name string, params MatrixParams) string { return name + "-" + params["pkg"] },
+		})
+	if !errors.Is(err, ErrDuplicateJobName) || e.Len() != 2 {
+		t.Errorf("duplicate names should return ErrDuplicateJobName and add nothing, got %v", err)
+	}
+	if _, err := e.AddMatrixJobs("x", "not a job", Matrix{"a": {"1"}}, MatrixOptions{}); !errors.Is(err, ErrInvalidMatrix) {
+		t.Errorf("unsupported job type should return ErrInvalidMatrix, got %v", err)
+	}
+	if _, err := e.AddMatrixJobs("x", TestRunnableSuccessFn, Matrix{"a": {}}, MatrixOptions{}); !errors.Is(err, ErrInvalidMatrix) {
+		t.Errorf("empty expansion should return ErrInvalidMatrix, got %v", err)
+	}
+}
+
+func Test_matrixJob(t *testing.T) {
+	var out bytes.Buffer
+	tpl := exec.Command("sh", "-c", "echo $GOOS")
+	tpl.Stdout, tpl.Dir, tpl.Env = &out, "/", []string{"A=1"}
+	j, err := matrixJob(tpl, MatrixParams{"goos": "linux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := j.(*exec.Cmd)
+	if cmd == tpl || cmd.Stdout != &out || cmd.Dir != "/" || strings.Join(cmd.Env, " ") != "A=1 GOOS=linux" {
+		t.Errorf("command should be copied with its settings, got %+v", cmd)
+	}
+	if len(tpl.Env) != 1 {
+		t.Errorf("template env should not be modified, got %v", tpl.Env)
+	}
+	if _, err := matrixJob(&exec.Cmd{}, MatrixParams{"a": "1"}); err != nil {
+		t.Errorf("command without args should not fail, got %v", err)
+	}
+}
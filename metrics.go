@@ -0,0 +1,263 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics receives measures from executors, the same Metrics can be shared
+// between multiple executors. Implementations must be safe for concurrent use.
+type Metrics interface {
+	JobStarted(name string)
+	JobSucceeded(name string)
+	JobFailed(name string)
+	// job not run because one of its dependencies failed
+	JobSkipped(name string)
+	ObserveJobDuration(name string, d time.Duration)
+	// time between a job is ready to run (its dependencies are resolved)
+	// and the moment it acquires a concurrency slot
+	ObserveQueueWait(name string, d time.Duration)
+	// update the number of currently running / pending jobs by delta
+	AddRunningJobs(delta int)
+	AddPendingJobs(delta int)
+}
+
+// Report jobs lifecycle to the given Metrics
+func (e *JobExecutor) WithMetrics(m Metrics) *JobExecutor {
+	e.onJobsStart(func(jobs JobList) {
+		pending := 0
+		for _, j := range jobs {
+			if j.IsState(JobStatePending) {
+				pending++
+			}
+		}
+		m.AddPendingJobs(pending)
+	})
+	e.onJobStart(func(jobs JobList, jobId int) {
+		j := jobs[jobId]
+		m.AddPendingJobs(-1)
+		wait := j.QueueWait()
+		m.AddRunningJobs(1)
+		m.JobStarted(j.Name())
+		m.ObserveQueueWait(j.Name(), wait)
+	})
+	e.onJobDone(func(jobs JobList, jobId int) {
+		j := jobs[jobId]
+		name := j.Name()
+		j.mutex.RLock()
+		err := j.Err
+		duration := j.Duration
+		j.mutex.RUnlock()
+		if errors.Is(err, ErrRequiredJobFailed) { // never started
+			m.AddPendingJobs(-1)
+			m.JobSkipped(name)
+			return
+		}
+		m.AddRunningJobs(-1)
+		switch {
+		case err != nil:
+			m.JobFailed(name)
+			m.ObserveJobDuration(name, duration)
+		default:
+			m.JobSucceeded(name)
+			m.ObserveJobDuration(name, duration)
+		}
+	})
+	return e
+}
+
+// ************************** Prometheus implementation **************************//
+
+// default histogram buckets in seconds
+var PrometheusBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 600}
+
+type histogram struct {
+	counts []uint64 // one count per bucket
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	for i, bound := range buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// PrometheusMetrics is a Metrics implementation that can be exposed in
+// prometheus text exposition format, it implements http.Handler so it can
+// directly be served as a /metrics endpoint.
+type PrometheusMetrics struct {
+	mutex     sync.Mutex
+	namespace string
+	buckets   []float64
+	started   uint64
+	succeeded uint64
+	failed    uint64
+	skipped   uint64
+	running   int
+	pending   int
+	durations map[string]*histogram
+	queueWait histogram
+}
+
+// return a new PrometheusMetrics, all metrics names will be prefixed with namespace_
+// (default to "jobexecutor" if empty)
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	if namespace == "" {
+		namespace = "jobexecutor"
+	}
+	return &PrometheusMetrics{
+		namespace: namespace,
+		buckets:   PrometheusBuckets,
+		durations: make(map[string]*histogram),
+	}
+}
+
+func (p *PrometheusMetrics) JobStarted(name string) {
+	p.mutex.Lock()
+	p.started++
+	p.mutex.Unlock()
+}
+func (p *PrometheusMetrics) JobSucceeded(name string) {
+	p.mutex.Lock()
+	p.succeeded++
+	p.mutex.Unlock()
+}
+func (p *PrometheusMetrics) JobFailed(name string) {
+	p.mutex.Lock()
+	p.failed++
+	p.mutex.Unlock()
+}
+func (p *PrometheusMetrics) JobSkipped(name string) {
+	p.mutex.Lock()
+	p.skipped++
+	p.mutex.Unlock()
+}
+func (p *PrometheusMetrics) ObserveJobDuration(name string, d time.Duration) {
+	p.mutex.Lock()
+	h, ok := p.durations[name]
+	if !ok {
+		h = &histogram{}
+		p.durations[name] = h
+	}
+	h.observe(p.buckets, d.Seconds())
+	p.mutex.Unlock()
+}
+func (p *PrometheusMetrics) ObserveQueueWait(name string, d time.Duration) {
+	p.mutex.Lock()
+	p.queueWait.observe(p.buckets, d.Seconds())
+	p.mutex.Unlock()
+}
+func (p *PrometheusMetrics) AddRunningJobs(delta int) {
+	p.mutex.Lock()
+	p.running += delta
+	p.mutex.Unlock()
+}
+func (p *PrometheusMetrics) AddPendingJobs(delta int) {
+	p.mutex.Lock()
+	p.pending += delta
+	p.mutex.Unlock()
+}
+
+func formatPromFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapePromLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func writePromHeader(buf *bytes.Buffer, name string, metricType string, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writePromHistogram(buf *bytes.Buffer, name string, labels string, buckets []float64, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, bound := range buckets {
+		var count uint64
+		if h.counts != nil {
+			count = h.counts[i]
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, formatPromFloat(bound), count)
+	}
+	fmt.Fprintf(buf, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(buf, "%s_sum%s %s\n", name, labels, formatPromFloat(h.sum))
+	fmt.Fprintf(buf, "%s_count%s %d\n", name, labels, h.count)
+}
+
+// Write all metrics in prometheus text exposition format
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	ns := p.namespace
+	p.mutex.Lock()
+	counters := []struct {
+		name  string
+		help  string
+		value uint64
+	}{
+		{"jobs_started_total", "Number of started jobs.", p.started},
+		{"jobs_succeeded_total", "Number of jobs that succeeded.", p.succeeded},
+		{"jobs_failed_total", "Number of jobs that failed.", p.failed},
+		{"jobs_skipped_total", "Number of jobs not run because a dependency failed.", p.skipped},
+	}
+	for _, c := range counters {
+		writePromHeader(&buf, ns+"_"+c.name, "counter", c.help)
+		fmt.Fprintf(&buf, "%s_%s %d\n", ns, c.name, c.value)
+	}
+	writePromHeader(&buf, ns+"_running_jobs", "gauge", "Number of jobs currently running.")
+	fmt.Fprintf(&buf, "%s_running_jobs %d\n", ns, p.running)
+	writePromHeader(&buf, ns+"_pending_jobs", "gauge", "Number of jobs waiting to run.")
+	fmt.Fprintf(&buf, "%s_pending_jobs %d\n", ns, p.pending)
+
+	writePromHeader(&buf, ns+"_job_duration_seconds", "histogram", "Duration of jobs by name.")
+	names := make([]string, 0, len(p.durations))
+	for name := range p.durations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writePromHistogram(&buf, ns+"_job_duration_seconds", `job="`+escapePromLabel(name)+`"`, p.buckets, p.durations[name])
+	}
+	writePromHeader(&buf, ns+"_queue_wait_seconds", "histogram", "Time jobs waited for a concurrency slot once ready.")
+	writePromHistogram(&buf, ns+"_queue_wait_seconds", "", p.buckets, &p.queueWait)
+	p.mutex.Unlock()
+	return buf.WriteTo(w)
+}
+
+// Serve metrics in prometheus text exposition format
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
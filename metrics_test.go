@@ -0,0 +1,52 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJobExecutor_WithMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics("")
+	e := NewExecutor().WithMetrics(metrics)
+	jobs := e.AddJobs(
+		NamedJob{"success", TestRunnableSuccessFn},
+		NamedJob{"fail", TestRunnableFailFn},
+		NamedJob{"skipped", TestRunnableSuccessFn},
+	)
+	e.AddJobDependency(jobs[2], jobs[1])
+	e.DagExecute()
+
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	out := string(body)
+	for _, want := range []string{
+		"jobexecutor_jobs_started_total 2\n", // skipped jobs are not started,
+		"jobexecutor_jobs_succeeded_total 1\n",
+		"jobexecutor_jobs_failed_total 1\n",
+		"jobexecutor_jobs_skipped_total 1\n",
+		"jobexecutor_running_jobs 0\n",
+		"jobexecutor_pending_jobs 0\n",
+		`jobexecutor_job_duration_seconds_count{job="success"} 1` + "\n",
+		`jobexecutor_job_duration_seconds_bucket{job="fail",le="+Inf"} 1` + "\n",
+		"jobexecutor_queue_wait_seconds_count 2\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output doesn't contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -10,21 +10,79 @@ package jobExecutor
 import (
 	"io"
 	"strings"
+	"sync"
 )
 
+// lines longer than this number of bytes are split by prefixedWriter,
+// this avoids buffering forever an output that never contains a new line
+var PrefixedWriterMaxLineLength = 64 * 1024
+
+// prefixedWriter prefixes each line written to it. Partial lines are buffered
+// until completed or until Flush is called, so each line is written at once
+// to the underlying writer. It is safe for concurrent use.
 type prefixedWriter struct {
+	mutex  sync.Mutex
 	prefix string
-	buf    io.Writer
+	// optional dynamic part printed before prefix on each line (ie: timestamps)
+	linePrefix func() string
+	// escape sequence applied to the content of each line
+	style string
+	buf   io.Writer
+	lines *lineWriter
+	// complete lines waiting to be written to buf
+	pending strings.Builder
 }
 
 func NewPrefixedWriter(buf io.Writer, prefix string) *prefixedWriter {
-	return &prefixedWriter{prefix: prefix, buf: buf}
+	pw := &prefixedWriter{prefix: prefix, buf: buf}
+	pw.lines = newLineWriter(pw.addLine)
+	pw.lines.maxLength = PrefixedWriterMaxLineLength
+	return pw
 }
-func (pb *prefixedWriter) replacer(b []byte) []byte {
-	bstring := string(b)
-	return []byte(pb.prefix + strings.Join(strings.Split(strings.TrimSuffix(bstring, "\n"), "\n"), "\n"+pb.prefix) + "\n")
+
+// return the full prefix of a line
+func (pb *prefixedWriter) getPrefix() string {
+	prefix := pb.prefix
+	if pb.linePrefix != nil {
+		prefix = pb.linePrefix() + prefix
+	}
+	if pb.style != "" {
+		prefix += pb.style
+	}
+	return prefix
 }
-func (b *prefixedWriter) Write(p []byte) (int, error) {
-	_, err := b.buf.Write([]byte(b.replacer(p)))
-	return len(p), err
+
+// add a prefixed line to the pending output, called with the mutex locked
+func (pb *prefixedWriter) addLine(line string) {
+	pb.pending.WriteString(pb.getPrefix())
+	pb.pending.WriteString(line)
+	if pb.style != "" {
+		pb.pending.WriteString("\033[0m")
+	}
+	pb.pending.WriteByte('\n')
+}
+
+// write pending lines to the underlying writer in a single call, called with the mutex locked
+func (pb *prefixedWriter) writePending() error {
+	if pb.pending.Len() == 0 {
+		return nil
+	}
+	_, err := io.WriteString(pb.buf, pb.pending.String())
+	pb.pending.Reset()
+	return err
+}
+
+func (pb *prefixedWriter) Write(p []byte) (int, error) {
+	pb.mutex.Lock()
+	defer pb.mutex.Unlock()
+	pb.lines.Write(p)
+	return len(p), pb.writePending()
+}
+
+// write the remaining partial line if any, followed by a new line
+func (pb *prefixedWriter) Flush() error {
+	pb.mutex.Lock()
+	defer pb.mutex.Unlock()
+	pb.lines.Flush()
+	return pb.writePending()
 }
@@ -9,6 +9,8 @@ package jobExecutor
 
 import (
 	"bytes"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -24,17 +26,80 @@ func TestNewPrefixedWriter(t *testing.T) {
 	}{
 		{"Should prefix a given line", args{"TESTING: ", "test line\n"}, "TESTING: test line\n"},
 		{"Should prefix a all lines when multiple lines", args{"TESTING: ", "test line\ntest line2\n"}, "TESTING: test line\nTESTING: test line2\n"},
-		{"Should ensure new line on input end", args{"TESTING: ", "test line"}, "TESTING: test line\n"},
-		{"Should ensure new line on input end", args{"TESTING: ", "test line\ntest line2"}, "TESTING: test line\nTESTING: test line2\n"},
+		{"Should ensure new line on flush", args{"TESTING: ", "test line"}, "TESTING: test line\n"},
+		{"Should ensure new line on flush", args{"TESTING: ", "test line\ntest line2"}, "TESTING: test line\nTESTING: test line2\n"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			buf := &bytes.Buffer{}
 			pw := NewPrefixedWriter(buf, tt.args.prefix)
 			pw.Write([]byte(tt.args.in))
+			pw.Flush()
 			if buf.String() != tt.want {
 				t.Errorf("Write = %v, want %v", buf, tt.want)
 			}
 		})
 	}
 }
+
+func TestPrefixedWriter_partialWrites(t *testing.T) {
+	buf := &bytes.Buffer{}
+	pw := NewPrefixedWriter(buf, "> ")
+	pw.Write([]byte("first li"))
+	if buf.Len() != 0 {
+		t.Fatalf("partial line should be buffered, got %q", buf.String())
+	}
+	pw.Write([]byte("ne\nsecond"))
+	if buf.String() != "> first line\n" {
+		t.Fatalf("completed line should be written, got %q", buf.String())
+	}
+	pw.Write([]byte(" line\nthird"))
+	pw.Flush()
+	if want := "> first line\n> second line\n> third\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	pw.Flush()
+	if want := "> first line\n> second line\n> third\n"; buf.String() != want {
+		t.Errorf("flush without pending line should not write anything, got %q", buf.String())
+	}
+}
+
+func TestPrefixedWriter_maxLineLength(t *testing.T) {
+	maxLength := PrefixedWriterMaxLineLength
+	PrefixedWriterMaxLineLength = 4
+	defer func() { PrefixedWriterMaxLineLength = maxLength }()
+	buf := &bytes.Buffer{}
+	pw := NewPrefixedWriter(buf, "> ")
+	pw.Write([]byte("abcdefghij"))
+	if want := "> abcd\n> efgh\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	buf.Reset()
+	pw.Write([]byte("\nabcé\n"))
+	if want := "> ij\n> abc\n> é\n"; buf.String() != want {
+		t.Errorf("multi-byte characters should not be split, got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestPrefixedWriter_concurrentWriters(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := newSyncWriter(buf)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pw := NewPrefixedWriter(out, "> ")
+			for j := 0; j < 100; j++ {
+				pw.Write([]byte("a line"))
+				pw.Write([]byte(" in two writes\n"))
+			}
+		}()
+	}
+	wg.Wait()
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if line != "> a line in two writes" {
+			t.Fatalf("lines of concurrent writers should not be mixed, got %q", line)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// delay between two progress lines when output is not a terminal
+var PlainProgressInterval = 5 * time.Second
+
+// estimate the remaining time to run all jobs based on the average duration
+// of completed jobs and the given concurrency limit, ok is false if it can't be estimated
+func estimateRemaining(jobs JobList, concurrency int) (eta time.Duration, ok bool) {
+	var total time.Duration
+	measured, remaining := 0, 0
+	for _, j := range jobs {
+		if !j.IsState(JobStateDone) {
+			remaining++
+			continue
+		}
+		j.mutex.RLock()
+		// jobs skipped because of a failed dependency would lower the average
+		if !errors.Is(j.Err, ErrRequiredJobFailed) {
+			total += j.Duration
+			measured++
+		}
+		j.mutex.RUnlock()
+	}
+	if measured == 0 || remaining == 0 {
+		return 0, false
+	}
+	if concurrency < 1 || concurrency > remaining {
+		concurrency = remaining
+	}
+	return time.Duration(int64(total) / int64(measured) * int64(remaining) / int64(concurrency)), true
+}
+
+// return " ETA <duration>" or an empty string if remaining time can't be estimated
+func formatETA(jobs JobList, concurrency int) string {
+	eta, ok := estimateRemaining(jobs, concurrency)
+	if !ok {
+		return ""
+	}
+	return " ETA " + eta.Round(time.Second).String()
+}
+
+// return the number of jobs the executor can run at once for the current run
+func (e *JobExecutor) maxConcurrency() int {
+	return e.opts.getLimiters().capacity()
+}
+
+// return the number of jobs in done state
+func countDoneJobs(jobs JobList) int {
+	done := 0
+	for _, j := range jobs {
+		if j.IsState(JobStateDone) {
+			done++
+		}
+	}
+	return done
+}
+
+// return a report that fits in maxLines lines: a summary line with the number
+// of jobs by state followed by the status lines of running jobs
+func (e *JobExecutor) collapsedProgressReport(jobs JobList, maxLines int) string {
+	counts := map[string]int{}
+	var running JobList
+	for _, j := range jobs {
+		state := j.stateName()
+		counts[state]++
+		if state == "running" {
+			running = append(running, j)
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d jobs: %d running, %d pending, %d succeed, %d failed%s\n",
+		len(jobs), counts["running"], counts["pending"], counts["succeed"], counts["failed"], formatETA(jobs, e.maxConcurrency()))
+	for i, j := range running {
+		if i >= maxLines-2 && len(running) > maxLines-1 {
+			fmt.Fprintf(&sb, "  … %d more running\n", len(running)-i)
+			break
+		}
+		sb.WriteString(e.execTemplate("jobStatusLine", j))
+	}
+	return sb.String()
+}
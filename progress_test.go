@@ -0,0 +1,121 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_estimateRemaining(t *testing.T) {
+	jobs := JobList{
+		{status: JobStateDone | JobStateSucceed, Duration: 2 * time.Second},
+		{status: JobStateDone | JobStateFailed, Duration: 4 * time.Second},
+		{status: JobStateDone | JobStateFailed, Err: ErrRequiredJobFailed},
+		{status: JobStateRunning},
+		{status: JobStatePending},
+		{status: JobStatePending},
+		{status: JobStatePending},
+	}
+	// 3s average, 4 remaining jobs on 2 slots
+	if eta, ok := estimateRemaining(jobs, 2); !ok || eta != 6*time.Second {
+		t.Errorf("estimateRemaining() = %v, %v, want 6s, true", eta, ok)
+	}
+	if got := formatETA(jobs, 2); got != " ETA 6s" {
+		t.Errorf("formatETA() = %q", got)
+	}
+	if _, ok := estimateRemaining(jobs[3:], 2); ok {
+		t.Error("estimateRemaining() should not estimate without completed jobs")
+	}
+	if _, ok := estimateRemaining(jobs[:3], 2); ok {
+		t.Error("estimateRemaining() should not estimate when all jobs are done")
+	}
+}
+
+func TestJobExecutor_collapsedProgressReport(t *testing.T) {
+	SetMaxConcurrentJobs(3)
+	defer SetMaxConcurrentJobs(0)
+	e := NewExecutor()
+	for i := 0; i < 10; i++ {
+		e.AddNamedJobFn(fmt.Sprintf("job%d", i), TestRunnableSuccessFn)
+	}
+	for _, j := range e.jobs[:6] {
+		j.status = JobStateRunning
+	}
+	e.jobs[9].status = JobStateDone | JobStateSucceed
+	e.jobs[9].Duration = 4 * time.Second
+	report := e.collapsedProgressReport(e.jobs, 4)
+	lines := strings.Split(strings.TrimSuffix(report, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("report should fit in 4 lines, got %d: %q", len(lines), report)
+	}
+	if lines[0] != "10 jobs: 6 running, 3 pending, 1 succeed, 0 failed ETA 12s" {
+		t.Errorf("unexpected summary line: %q", lines[0])
+	}
+	if lines[3] != "  … 4 more running" {
+		t.Errorf("unexpected last line: %q", lines[3])
+	}
+	if report := e.collapsedProgressReport(e.jobs, 10); strings.Count(report, "\n") != 7 {
+		t.Errorf("all running jobs should be listed when there is enough room: %q", report)
+	}
+}
+
+func TestJobExecutor_WithProgressBarOutput_plain(t *testing.T) {
+	interval := PlainProgressInterval
+	PlainProgressInterval = 10 * time.Millisecond
+	defer func() { PlainProgressInterval = interval }()
+	var out bytes.Buffer
+	NewExecutor().SetOutput(&out).
+		AddJobFns(TestRunnableSuccessFn, func() (string, error) {
+			time.Sleep(50 * time.Millisecond)
+			return "", nil
+		}).
+		WithProgressBarOutput(0, true, "\033[32m").
+		Execute()
+	got := out.String()
+	if strings.Contains(got, "\033") {
+		t.Errorf("output should not contain ANSI escape sequences: %q", got)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) < 2 {
+		t.Errorf("periodic progress lines should be printed: %q", got)
+	}
+	if lines[len(lines)-1] != "2/2 jobs done" {
+		t.Errorf("last line should report all jobs done: %q", got)
+	}
+}
+
+func Test_progressBarLine(t *testing.T) {
+	jobs := JobList{{status: JobStateDone | JobStateSucceed, Duration: time.Minute}, {status: JobStatePending}}
+	want := "\033[2K \033[32m" + progressBarString(1, 2, 40) + "\033[0m 1/2 ETA 1m0s\r"
+	if got := progressBarLine(jobs, &bytes.Buffer{}, 0, "\033[32m", 1); got != want {
+		t.Errorf("progressBarLine() = %q, want %q", got, want)
+	}
+}
+
+func TestJobExecutor_maxConcurrency(t *testing.T) {
+	SetMaxConcurrentJobs(4)
+	defer SetMaxConcurrentJobs(0)
+	e := NewExecutor()
+	if got := e.maxConcurrency(); got != 4 {
+		t.Errorf("maxConcurrency() = %d, want the global limit 4", got)
+	}
+	if got := e.WithMaxConcurrentJobs(2).maxConcurrency(); got != 2 {
+		t.Errorf("maxConcurrency() = %d, want the executor limit 2", got)
+	}
+	var duringRun int
+	sub := NewExecutor().WithMaxConcurrentJobs(3)
+	sub.AddJobFns(func() (string, error) { duringRun = sub.maxConcurrency(); return "", nil })
+	NewExecutor().WithMaxConcurrentJobs(1).AddSubExecutor("sub", sub).DagExecute()
+	if duringRun != 1 {
+		t.Errorf("maxConcurrency() = %d, want the parent limit 1 when run as a sub executor", duringRun)
+	}
+}
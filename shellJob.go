@@ -0,0 +1,186 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var ErrInvalidShellScript = fmt.Errorf("invalid shell script")
+
+// shell used by shell jobs when none is set with SetShell, the script is
+// appended as the last argument
+var DefaultShell = []string{"sh", "-c"}
+
+// ShellJobOptions customize a shell job, see AddShellJobWithOptions
+type ShellJobOptions struct {
+	// shell and its arguments, the script is appended as the last argument.
+	// default to the executor shell (see SetShell)
+	Shell []string
+	// environment variables added to (or overriding) the current environment
+	Env map[string]string
+	// working directory of the command, default to the current one
+	Dir string
+	// parameters passed to the script as JOB_PARAM_<name> environment variables
+	Params map[string]string
+	// interpolate the script as a template before running it (see
+	// AddShellJobWithOptions), scripts are run as is otherwise
+	Interpolate bool
+}
+
+// data available when interpolating a shell script, values are shell quoted
+type shellScriptData struct {
+	Params map[string]string
+	// outputs of the dependencies by job name, without trailing new lines
+	Outputs map[string]string
+}
+
+// quote s so it is passed as a single word to a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// return name with characters other than letters, digits and underscores
+// replaced by "_" so it can be used as an environment variable name
+func envVarName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// return the entries of m as environment variables in name order, names are
+// prefixed with prefix and made valid with envVarName
+func prefixedEnv(prefix string, m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	env := make([]string, len(keys))
+	for i, k := range keys {
+		env[i] = prefix + envVarName(k) + "=" + m[k]
+	}
+	return env
+}
+
+// Set the shell used by shell jobs added afterwards, the script is appended
+// as the last argument, ie: SetShell("bash", "-o", "pipefail", "-c").
+// Default to DefaultShell when called without arguments.
+// This method can be chained.
+func (e *JobExecutor) SetShell(shell ...string) *JobExecutor {
+	e.shell = shell
+	return e
+}
+
+// Add a named job running script with the executor shell ("sh -c" by
+// default), see AddShellJobWithOptions.
+// This method can be chained.
+func (e *JobExecutor) AddShellJob(name string, script string) *JobExecutor {
+	return e.AddShellJobWithOptions(name, script, ShellJobOptions{})
+}
+
+// Add a named job running script through a shell. The script gets opts.Params
+// as JOB_PARAM_<name> environment variables and the output of its
+// dependencies (without trailing new lines) as JOB_OUTPUT_<job name>
+// variables, characters of names other than letters, digits and underscores
+// are replaced by "_". Use them quoted: "$JOB_OUTPUT_version".
+//
+// When opts.Interpolate is set the script is also a template interpolated
+// right before the job runs with {{.Params.name}} and {{.Outputs.name}}, their
+// values are shell quoted so they are passed as a single word. Referring to a
+// job which is not a dependency or a template that can't be parsed fails the
+// job with ErrInvalidShellScript. Template functions (see AddTemplateFuncs)
+// can be used too.
+//
+// It panics with ErrDuplicateJobName if name is already used.
+// This method can be chained.
+//
+//	executor.AddShellJob("version", "git describe --tags").
+//		AddShellJob("build", `go build -ldflags "-X main.version=$JOB_OUTPUT_version" ./...`)
+//	executor.DependsOn("build", "version")
+func (e *JobExecutor) AddShellJobWithOptions(name string, script string, opts ShellJobOptions) *JobExecutor {
+	shell := opts.Shell
+	if len(shell) == 0 {
+		shell = e.shell
+	}
+	if len(shell) == 0 {
+		shell = DefaultShell
+	}
+	cmd := exec.Command(shell[0], append(shell[1:len(shell):len(shell)], script)...)
+	cmd.Dir = opts.Dir
+	// later values override earlier ones
+	baseEnv := prefixedEnv("JOB_PARAM_", opts.Params)
+	envKeys := make([]string, 0, len(opts.Env))
+	for k := range opts.Env {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+	for _, k := range envKeys {
+		baseEnv = append(baseEnv, k+"="+opts.Env[k])
+	}
+	var tpl *template.Template
+	var parseErr error
+	if opts.Interpolate {
+		tpl, parseErr = template.New(name).
+			Funcs(e.getTemplateFuncs()).
+			Option("missingkey=error").
+			Parse(script)
+	}
+	e.AddNamedJobCmd(name, cmd)
+	j := e.jobs[e.Len()-1]
+	j.prepare = func() error {
+		if parseErr != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidShellScript, parseErr)
+		}
+		outputs := map[string]string{}
+		j.mutex.RLock()
+		dependsOn := j.DependsOn
+		j.mutex.RUnlock()
+		for _, dep := range dependsOn {
+			dep.mutex.RLock()
+			outputs[dep.Name()] = strings.TrimRight(dep.Res, "\r\n")
+			dep.mutex.RUnlock()
+		}
+		var env []string
+		if len(baseEnv) > 0 || len(outputs) > 0 {
+			env = append(append(os.Environ(), baseEnv...), prefixedEnv("JOB_OUTPUT_", outputs)...)
+		}
+		var res strings.Builder
+		if tpl != nil {
+			data := shellScriptData{Params: map[string]string{}, Outputs: map[string]string{}}
+			for k, v := range opts.Params {
+				data.Params[k] = shellQuote(v)
+			}
+			for k, v := range outputs {
+				data.Outputs[k] = shellQuote(v)
+			}
+			if err := tpl.Execute(&res, data); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidShellScript, err)
+			}
+		}
+		j.mutex.Lock()
+		j.Cmd.Env = env
+		if tpl != nil {
+			// copy args as they may be shared with a clone (see job.reset)
+			args := append([]string{}, j.Cmd.Args...)
+			args[len(args)-1] = res.String()
+			j.Cmd.Args = args
+		}
+		j.mutex.Unlock()
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func Test_shellQuote(t *testing.T) {
+	out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(`it's "quoted" $HOME`)).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `it's "quoted" $HOME` {
+		t.Errorf("shellQuote() should preserve the value, got %q", out)
+	}
+}
+
+func TestJobExecutor_AddShellJob(t *testing.T) {
+	dir := t.TempDir()
+	e := NewExecutor().
+		AddShellJob("version", "echo v1.2.3").
+		AddShellJob("inject", `echo 'x"; echo INJECTED; echo "'`).
+		AddShellJobWithOptions("build", `echo "$GREETING $JOB_PARAM_target $JOB_OUTPUT_version" | tr a-z A-Z; pwd`, ShellJobOptions{
+			Env:    map[string]string{"GREETING": "hello"},
+			Dir:    dir,
+			Params: map[string]string{"target": "linux"},
+		}).
+		AddShellJob("raw", `echo "$JOB_OUTPUT_inject"; echo '{{.ID}}'`).
+		AddShellJobWithOptions("interpolated", `printf '%s|' {{.Outputs.inject}} {{.Params.p}}`, ShellJobOptions{
+			Shell:       []string{"/bin/sh", "-c"},
+			Params:      map[string]string{"p": "it's"},
+			Interpolate: true,
+		})
+	for name, deps := range map[string][]string{"build": {"version"}, "raw": {"inject"}, "interpolated": {"inject"}} {
+		if err := e.DependsOn(name, deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if errs := e.DagExecute(); errs.Len() != 0 {
+		t.Fatal(errs)
+	}
+	build, _ := e.GetJob("build")
+	if !build.IsCmdJob() || build.Name() != "build" {
+		t.Errorf("shell job should be a named command job, got %q", build.Name())
+	}
+	want := "HELLO LINUX V1.2.3\n" + dir + "\n"
+	if got := build.CombinedOutput(); got != want {
+		t.Errorf("unexpected output %q, want %q", got, want)
+	}
+	raw, _ := e.GetJob("raw")
+	if got := raw.CombinedOutput(); got != "x\"; echo INJECTED; echo \"\n{{.ID}}\n" {
+		t.Errorf("scripts should not be interpolated by default and outputs passed as is, got %q", got)
+	}
+	interpolated, _ := e.GetJob("interpolated")
+	if got := interpolated.CombinedOutput(); got != `x"; echo INJECTED; echo "|it's|` {
+		t.Errorf("interpolated values should be quoted, got %q", got)
+	}
+
+	// run again as done by DagWatch
+	for _, j := range e.jobs {
+		j.reset()
+	}
+	if errs := e.DagExecute(); errs.Len() != 0 || build.CombinedOutput() != want {
+		t.Errorf("shell job should be prepared again, got %v %q", errs, build.CombinedOutput())
+	}
+}
+
+func Test_prefixedEnv(t *testing.T) {
+	got := strings.Join(prefixedEnv("JOB_OUTPUT_", map[string]string{"b": "2", "build (goos=linux)": "1"}), " ")
+	if got != "JOB_OUTPUT_b=2 JOB_OUTPUT_build__goos_linux_=1" {
+		t.Errorf("prefixedEnv() = %q", got)
+	}
+}
+
+func TestJobExecutor_AddShellJob_errors(t *testing.T) {
+	e := NewExecutor().SetShell("bash", "-o", "pipefail", "-c").
+		AddShellJobWithOptions("unknown", "echo {{.Outputs.missing}}", ShellJobOptions{Interpolate: true}).
+		AddShellJobWithOptions("invalid", "echo {{", ShellJobOptions{Interpolate: true}).
+		AddShellJob("pipefail", "false | true")
+	errs := e.DagExecute()
+	for id, name := range []string{"unknown", "invalid"} {
+		if !errors.Is(errs[id], ErrInvalidShellScript) {
+			t.Errorf("%s should fail with ErrInvalidShellScript, got %v", name, errs[id])
+		}
+	}
+	if _, err := exec.LookPath("bash"); err == nil && (errs[2] == nil || !strings.Contains(errs[2].Error(), "exit status 1")) {
+		t.Errorf("SetShell should be used by shell jobs, got %v", errs[2])
+	}
+}
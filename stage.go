@@ -0,0 +1,202 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrJobInStage = fmt.Errorf("job already belongs to a stage")
+
+type stage struct {
+	name string
+	jobs []*job
+}
+
+// StageView is a read-only snapshot of a stage passed to stage templates
+type StageView struct {
+	Name string
+	Jobs []JobView
+	// state of each job: pending, running, succeed, failed, canceled or skipped
+	// (failed dependency), see nodeState
+	states []string
+}
+
+// return the number of jobs of the stage in the given state: pending,
+// running, succeed, failed, canceled or skipped (not run as a dependency failed)
+func (s StageView) Count(state string) int {
+	count := 0
+	for _, jobState := range s.states {
+		if jobState == state {
+			count++
+		}
+	}
+	return count
+}
+
+// return pending until a job starts, running until all jobs are done then
+// succeed if all jobs succeed, skipped if none were run or failed
+func (s StageView) State() string {
+	pending, running := s.Count("pending"), s.Count("running")
+	switch {
+	case pending == len(s.states):
+		return "pending"
+	case pending > 0 || running > 0:
+		return "running"
+	case s.Count("succeed") == len(s.states):
+		return "succeed"
+	case s.Count("skipped") == len(s.states):
+		return "skipped"
+	}
+	return "failed"
+}
+
+// return the time between the start of the first job and the end of the last
+// one, or the time elapsed since the first job started if some are running.
+// skipped jobs are ignored
+func (s StageView) Duration() time.Duration {
+	var start, end time.Time
+	for i, state := range s.states {
+		j := s.Jobs[i]
+		switch state {
+		case "skipped", "pending":
+			continue
+		case "running":
+			end = time.Now()
+		default:
+			if t := j.EndTime(); t.After(end) {
+				end = t
+			}
+		}
+		if t := j.StartTime(); !t.IsZero() && (start.IsZero() || t.Before(start)) {
+			start = t
+		}
+	}
+	if start.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func (s *stage) view() StageView {
+	view := StageView{Name: s.name, Jobs: make([]JobView, len(s.jobs)), states: make([]string, len(s.jobs))}
+	for i, j := range s.jobs {
+		view.Jobs[i] = j.view()
+		view.states[i] = nodeState(j)
+	}
+	return view
+}
+
+// return the stage with the given name and its index or nil and -1
+func (e *JobExecutor) getStage(name string) (*stage, int) {
+	for i, s := range e.stages {
+		if s.name == name {
+			return s, i
+		}
+	}
+	return nil, -1
+}
+
+// Add jobs to the named stage, stages are run in the order they are first
+// declared: every job of a stage depends on all jobs of the previous stage
+// with jobs. Adding jobs to an existing stage also makes jobs of the next
+// stage with jobs depend on them. A job can only belong to a single stage and
+// be added once, it panics with ErrJobInStage otherwise. This method can be chained.
+//
+//	executor.Stage("build", build).
+//		Stage("test", unit, lint).
+//		Stage("release", executor.JobsWithTag("release")...)
+func (e *JobExecutor) Stage(name string, jobs ...Job) *JobExecutor {
+	added := make(map[*job]bool, len(jobs))
+	for _, j := range jobs {
+		if j.job.stage != "" {
+			panic(fmt.Errorf("%w: %q is in stage %q", ErrJobInStage, j.Name(), j.job.stage))
+		} else if added[j.job] {
+			panic(fmt.Errorf("%w: %q is added twice to stage %q", ErrJobInStage, j.Name(), name))
+		}
+		added[j.job] = true
+	}
+	s, index := e.getStage(name)
+	if s == nil {
+		s, index = &stage{name: name}, len(e.stages)
+		e.stages = append(e.stages, s)
+	}
+	// empty stages are not barriers, link to the nearest stages with jobs
+	var previous, next []*job
+	for i := index - 1; i >= 0 && previous == nil; i-- {
+		previous = e.stages[i].jobs
+	}
+	for i := index + 1; i < len(e.stages) && next == nil; i++ {
+		next = e.stages[i].jobs
+	}
+	for _, j := range jobs {
+		j.job.stage = name
+		s.jobs = append(s.jobs, j.job)
+		for _, dep := range previous {
+			e.AddJobDependency(j, Job{job: dep})
+		}
+		for _, dependent := range next {
+			e.AddJobDependency(Job{job: dependent}, j)
+		}
+	}
+	return e
+}
+
+// Return a snapshot of the stages in the order they run
+func (e *JobExecutor) Stages() []StageView {
+	views := make([]StageView, len(e.stages))
+	for i, s := range e.stages {
+		views[i] = s.view()
+	}
+	return views
+}
+
+// Display the "stageStart" template when the first job of a stage starts and
+// the "stageSummary" template once all jobs of a stage are done (including
+// stages whose jobs were all skipped), both receive a StageView.
+// Jobs outside of any stage are ignored.
+func (e *JobExecutor) WithStageOutput() *JobExecutor {
+	var mutex sync.Mutex
+	started := make(map[string]bool)
+	ended := make(map[string]bool)
+	e.onJobsStart(func(jobs JobList) {
+		mutex.Lock()
+		started = make(map[string]bool)
+		ended = make(map[string]bool)
+		mutex.Unlock()
+	})
+	e.onJobStart(func(jobs JobList, jobId int) {
+		s, _ := e.getStage(jobs[jobId].stage)
+		if s == nil {
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !started[s.name] {
+			started[s.name] = true
+			fmt.Fprint(e.getOutput(), e.execTemplate("stageStart", s.view()))
+		}
+	})
+	e.onJobDone(func(jobs JobList, jobId int) {
+		s, _ := e.getStage(jobs[jobId].stage)
+		if s == nil {
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		view := s.view()
+		if ended[s.name] || view.State() == "running" || view.State() == "pending" {
+			return
+		}
+		ended[s.name] = true
+		fmt.Fprint(e.getOutput(), e.execTemplate("stageSummary", view))
+	})
+	return e
+}
@@ -0,0 +1,120 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestJobExecutor_Stage(t *testing.T) {
+	e := NewExecutor()
+	build := e.AddJob(NamedJob{"build", TestRunnableSuccessFn})
+	unit := e.AddJob(NamedJob{"unit", TestRunnableSuccessFn})
+	lint := e.AddJob(NamedJob{"lint", TestRunnableSuccessFn})
+	release := e.AddJob(NamedJob{"release", TestRunnableSuccessFn})
+	generate := e.AddJob(NamedJob{"generate", TestRunnableSuccessFn})
+	e.Stage("build", build).
+		Stage("test", unit, lint).
+		Stage("release", release).
+		Stage("build", generate) // existing stage, test jobs must depend on it too
+	want := []string{"build: ", "unit: build generate", "lint: build generate", "release: unit lint", "generate: "}
+	if got := describeDeps(e); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected dependencies %v, want %v", got, want)
+	}
+	stages := e.Stages()
+	if len(stages) != 3 || stages[1].Name != "test" || len(stages[0].Jobs) != 2 || stages[0].Jobs[1].Stage() != "build" {
+		t.Errorf("unexpected stages %v", stages)
+	}
+	if stages[2].State() != "pending" || stages[2].Duration() != 0 || stages[2].Count("pending") != 1 {
+		t.Errorf("stage should be pending before running")
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrJobInStage) {
+			t.Errorf("adding a job to a second stage should panic with ErrJobInStage, got %v", err)
+		}
+	}()
+	e.Stage("other", unit)
+}
+
+func TestJobExecutor_StageDuplicate(t *testing.T) {
+	e := NewExecutor()
+	build := e.AddJob(NamedJob{"build", TestRunnableSuccessFn})
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrJobInStage) || build.job.stage != "" {
+			t.Errorf("adding a job twice to a stage should panic with ErrJobInStage, got %v", err)
+		}
+	}()
+	e.Stage("build", build, build)
+}
+
+func TestStageView_Duration(t *testing.T) {
+	start := time.Now()
+	view := StageView{
+		Jobs:   []JobView{{startTime: start, endTime: start.Add(2 * time.Second)}, {}},
+		states: []string{"succeed", "canceled"}, // canceled before it started
+	}
+	if got := view.Duration(); got != 2*time.Second {
+		t.Errorf("jobs that never started should be ignored, got %v", got)
+	}
+}
+
+func TestJobExecutor_StageEmpty(t *testing.T) {
+	e := NewExecutor()
+	build := e.AddJob(NamedJob{"build", TestRunnableSuccessFn})
+	test := e.AddJob(NamedJob{"test", TestRunnableSuccessFn})
+	lint := e.AddJob(NamedJob{"lint", TestRunnableSuccessFn})
+	e.Stage("build", build).
+		Stage("lint").
+		Stage("integration", e.JobsWithTag("integration")...).
+		Stage("test", test)
+	if want := []string{"build: ", "test: build", "lint: "}; !reflect.DeepEqual(describeDeps(e), want) {
+		t.Errorf("empty stages should not break the barrier, got %v", describeDeps(e))
+	}
+	// filling an empty stage links it with its nearest non empty neighbours
+	e.Stage("lint", lint)
+	if want := []string{"build: ", "test: build lint", "lint: build"}; !reflect.DeepEqual(describeDeps(e), want) {
+		t.Errorf("unexpected dependencies %v", describeDeps(e))
+	}
+}
+
+func TestJobExecutor_WithStageOutput(t *testing.T) {
+	var out bytes.Buffer
+	e := NewExecutor().SetOutput(&out).WithStageOutput()
+	build := e.AddJob(NamedJob{"build", TestRunnableSuccessFn})
+	unit := e.AddJob(NamedJob{"unit", TestRunnableSuccessFn})
+	lint := e.AddJob(NamedJob{"lint", TestRunnableFailFn})
+	release := e.AddJob(NamedJob{"release", TestRunnableSuccessFn})
+	e.AddNamedJobFn("outside", TestRunnableSuccessFn)
+	e.Stage("build", build).Stage("test", unit, lint).Stage("release", release)
+	e.DagExecute()
+
+	got := out.String()
+	want := regexp.MustCompile(`^▶ stage build: 1 job
+👍 stage build: 1/1 succeed in \S+
+▶ stage test: 2 jobs
+💥 stage test: 1/2 succeed, 1 failed in \S+
+⏭ stage release: skipped
+$`)
+	// the job outside of stages may run at any time but prints nothing
+	if !want.MatchString(got) {
+		t.Errorf("unexpected stage output:\n%s", got)
+	}
+	stages := e.Stages()
+	if stages[0].State() != "succeed" || stages[1].State() != "failed" || stages[1].Count("failed") != 1 {
+		t.Errorf("unexpected stage states %v %v", stages[0].State(), stages[1].State())
+	}
+	if stages[2].State() != "skipped" || stages[2].Count("skipped") != 1 || stages[2].Count("failed") != 0 || stages[2].Duration() != 0 {
+		t.Errorf("jobs of the release stage should be skipped, got %v", stages[2].State())
+	}
+}
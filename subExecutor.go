@@ -0,0 +1,93 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSubExecutor = fmt.Errorf("invalid sub executor")
+
+// SubExecutorError is the error of a job running a sub executor, it holds
+// the errors of the failed jobs of the sub executor
+type SubExecutorError struct {
+	// errors of the sub executor jobs indexed by job id
+	Errors JobsError
+	// number of jobs of the sub executor
+	Total int
+}
+
+func (e *SubExecutorError) Error() string {
+	return fmt.Sprintf("%d/%d jobs failed", len(e.Errors), e.Total)
+}
+
+// allow errors.Is and errors.As to match errors of the sub executor jobs
+func (e *SubExecutorError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for id := 0; id < e.Total; id++ {
+		if err, ok := e.Errors[id]; ok {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// attach sub to e, it panics with ErrInvalidSubExecutor if sub is already
+// attached to an executor or if it would nest an executor in itself
+func (e *JobExecutor) attachSubExecutor(sub *JobExecutor) {
+	if sub.parent != nil {
+		panic(fmt.Errorf("%w: already added to another executor", ErrInvalidSubExecutor))
+	}
+	for p := e; p != nil; p = p.parent {
+		if p == sub {
+			panic(fmt.Errorf("%w: an executor can't be nested in itself", ErrInvalidSubExecutor))
+		}
+	}
+	sub.parent = e
+}
+
+// run the executor as a job of another executor: its jobs are run respecting
+// dependencies and the output is the full status of each of them.
+// The slots held by the parent job are released while the jobs run so they
+// share the parent concurrency limit.
+func (e *JobExecutor) runAsJob(parentLimiters limiters) (string, error) {
+	for _, j := range e.jobs {
+		j.reset()
+	}
+	if parentLimiters != nil {
+		parentLimiters.release()
+		defer parentLimiters.acquire()
+	}
+	errs := e.dagExecute(parentLimiters)
+	var out strings.Builder
+	for _, j := range e.jobs {
+		out.WriteString(e.execTemplate("jobStatusFull", j))
+	}
+	if errs.Len() > 0 {
+		return out.String(), &SubExecutorError{Errors: errs, Total: e.Len()}
+	}
+	return out.String(), nil
+}
+
+// Add a whole executor as a single named job, its jobs run respecting their
+// dependencies when the job starts and the job output is made of their
+// statuses and outputs rendered with the sub executor "jobStatusFull"
+// template (so they end up indented in the parent reports). The job fails
+// with a *SubExecutorError if any job of the sub executor fails.
+// While running, the parent job gives its concurrency slot back to the
+// jobs of the sub executor so they share the parent concurrency limit, use
+// WithMaxConcurrentJobs on the sub executor to bound it further.
+// An executor can only be added to a single executor and can't be nested in
+// itself, it panics with ErrInvalidSubExecutor otherwise.
+// It panics with ErrDuplicateJobName if name is already used.
+// This method can be chained.
+func (e *JobExecutor) AddSubExecutor(name string, sub *JobExecutor) *JobExecutor {
+	e.AddJob(NamedJob{Name: name, Job: sub})
+	return e
+}
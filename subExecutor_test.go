@@ -0,0 +1,166 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobExecutor_AddSubExecutor(t *testing.T) {
+	// a single slot must not prevent the sub executor jobs from running
+	SetMaxConcurrentJobs(1)
+	defer SetMaxConcurrentJobs(0)
+	sub := NewExecutor().
+		AddNamedJobFn("unit", TestRunnableSuccessFn).
+		AddNamedJobFn("lint", TestRunnableFailFn)
+	var out bytes.Buffer
+	e := NewExecutor().SetOutput(&out).
+		AddNamedJobFn("build", TestRunnableSuccessFn).
+		AddSubExecutor("tests", sub).
+		WithOrderedOutput()
+	if err := e.DependsOn("tests", "build"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan JobsError)
+	go func() { done <- e.DagExecute() }()
+	var errs JobsError
+	select {
+	case errs = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sub executor should not wait for its parent slot")
+	}
+
+	var subErr *SubExecutorError
+	if !errors.As(errs[1], &subErr) || subErr.Total != 2 || subErr.Errors.Len() != 1 {
+		t.Fatalf("sub executor errors should be surfaced, got %v", errs)
+	}
+	if subErr.Error() != "1/2 jobs failed" || !strings.Contains(subErr.Errors[1].Error(), "test error") {
+		t.Errorf("unexpected error %q", subErr)
+	}
+	tests, _ := e.GetJob("tests")
+	if !tests.IsState(JobStateFailed) {
+		t.Errorf("sub executor job should fail when one of its jobs fails")
+	}
+	if got := out.String(); !strings.Contains(got, " unit:\n    done\n") || !strings.Contains(got, "\n  💥 Error    lint: test error\n") {
+		t.Errorf("sub executor output should be nested in the report:\n%s", got)
+	}
+	if name := (&Job{job: &job{sub: sub}}).Name(); name != "JobExecutor(2 jobs)" {
+		t.Errorf("unnamed sub executor job name = %q", name)
+	}
+
+	// run again as parent jobs are reset by DagWatch
+	sub = NewExecutor().AddNamedJobFn("ok", TestRunnableSuccessFn)
+	e = NewExecutor().AddSubExecutor("sub", sub)
+	for i := 0; i < 2; i++ {
+		e.jobs[0].reset()
+		if errs := e.DagExecute(); errs.Len() != 0 {
+			t.Errorf("run %d: unexpected errors %v", i, errs)
+		}
+	}
+}
+
+func TestJobExecutor_WithMaxConcurrentJobs(t *testing.T) {
+	var running, maxRunning int32
+	fn := func() (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return "", nil
+	}
+	e := NewExecutor().WithMaxConcurrentJobs(2).AddJobFns(fn, fn, fn, fn, fn)
+	e.DagExecute()
+	if maxRunning != 2 {
+		t.Errorf("executor should run at most 2 jobs at once, got %d", maxRunning)
+	}
+	if e.WithMaxConcurrentJobs(0).opts.limiter != nil {
+		t.Errorf("WithMaxConcurrentJobs(0) should restore the package limit")
+	}
+}
+
+func TestJobExecutor_cancelSubExecutor(t *testing.T) {
+	sub := NewExecutor().AddNamedJobFn("a", TestRunnableSuccessFn)
+	e := NewExecutor()
+	j := e.AddJob(NamedJob{"sub", sub})
+	j.Cancel()
+	if !sub.jobs[0].canceled {
+		t.Errorf("canceling a sub executor job should cancel its jobs")
+	}
+}
+
+func TestJobExecutor_subExecutorsShareConcurrency(t *testing.T) {
+	SetMaxConcurrentJobs(2)
+	defer SetMaxConcurrentJobs(0)
+	var running, maxRunning int32
+	fn := func() (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return "", nil
+	}
+	e := NewExecutor()
+	for i := 0; i < 3; i++ {
+		e.AddJob(NewExecutor().AddJobFns(fn, fn, fn))
+	}
+	done := make(chan JobsError)
+	go func() { done <- e.DagExecute() }()
+	select {
+	case errs := <-done:
+		if errs.Len() != 0 {
+			t.Fatal(errs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sub executors should not deadlock")
+	}
+	if maxRunning > 2 {
+		t.Errorf("sub executors should share the global limit, got %d jobs running at once", maxRunning)
+	}
+	for i := 0; i < 2; i++ {
+		e.DagExecute()
+	}
+	if e.jobs[0].sub.opts.onJobDone != nil {
+		t.Errorf("running a sub executor should not register handlers on it")
+	}
+}
+
+func TestJobExecutor_AddSubExecutor_invalid(t *testing.T) {
+	assertPanics := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if err, _ := recover().(error); !errors.Is(err, ErrInvalidSubExecutor) {
+				t.Errorf("%s should panic with ErrInvalidSubExecutor, got %v", name, err)
+			}
+		}()
+		fn()
+	}
+	a, b, c := NewExecutor(), NewExecutor(), NewExecutor()
+	assertPanics("self", func() { a.AddJob(a) })
+	a.AddSubExecutor("b", b)
+	b.AddSubExecutor("c", c)
+	assertPanics("cycle", func() { c.AddJob(a) })
+	assertPanics("already attached", func() { NewExecutor().AddJob(b) })
+	if a.Len() != 1 || b.Len() != 1 || c.Len() != 0 {
+		t.Errorf("invalid sub executors should not be added")
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var ErrMissingTemplate = fmt.Errorf("missing required template")
+
+// templates that must be defined by a template string, see JobExecutor.SetTemplateString
+var RequiredTemplates = []string{
+	"startSummary",
+	"jobStatusLine",
+	"jobStatusFull",
+	"doneReport",
+	"startProgressReport",
+	"progressReport",
+}
+
+var templateStyles = map[string]string{
+	"reset":     "0",
+	"bold":      "1",
+	"dim":       "2",
+	"italic":    "3",
+	"underline": "4",
+	"black":     "30",
+	"red":       "31",
+	"green":     "32",
+	"yellow":    "33",
+	"blue":      "34",
+	"magenta":   "35",
+	"cyan":      "36",
+	"white":     "37",
+	"gray":      "90",
+}
+
+// format a duration with a precision that depends on its magnitude
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(10 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// return s wrapped in the escape sequences for the given space separated
+// styles (ie: "bold red"), or s unchanged if ansi is false
+func colorString(ansi bool, style string, s string) (string, error) {
+	var codes []string
+	for _, name := range strings.Fields(style) {
+		code, ok := templateStyles[name]
+		if !ok {
+			return "", fmt.Errorf("unknown style %q", name)
+		}
+		codes = append(codes, code)
+	}
+	if !ansi || len(codes) == 0 {
+		return s, nil
+	}
+	return "\033[" + strings.Join(codes, ";") + "m" + s + "\033[0m", nil
+}
+
+func pluralize(n int, singular string, plural string) string {
+	if n > 1 || n < -1 {
+		return plural
+	}
+	return singular
+}
+
+// return the functions available in all templates
+//   - indent N s: indent each line of s with N spaces
+//   - trim s: remove leading and trailing new lines
+//   - duration d: format a time.Duration with a precision depending on its magnitude
+//   - color "style" s: apply space separated styles to s (bold, dim, italic,
+//     underline, black, red, green, yellow, blue, magenta, cyan, white, gray)
+//     styles are only applied when ANSI is enabled on the executor output
+//   - pad N s / truncate N s: pad or truncate s to N characters
+//   - stateName job: pending, running, succeed or failed
+//   - exitCode job: exit code of a command job, -1 if not available
+//   - tail N s: last N lines of s
+//   - pluralize N "singular" "plural": choose a word according to N
+func templateFuncs(ansi func() bool) template.FuncMap {
+	return template.FuncMap{
+		"indent":   indent,
+		"trim":     trim,
+		"duration": formatDuration,
+		"color": func(style string, s string) (string, error) {
+			return colorString(ansi(), style, s)
+		},
+		"pad":       func(width int, s string) string { return padString(s, width) },
+		"truncate":  func(width int, s string) string { return truncateString(s, width) },
+		"stateName": func(j JobView) string { return j.State() },
+		"exitCode":  func(j JobView) int { return j.ExitCode() },
+		"tail": func(n int, s string) string {
+			tail, _ := tailLines(s, n)
+			return tail
+		},
+		"pluralize": pluralize,
+	}
+}
+
+// check all RequiredTemplates are defined in tpl
+func validateTemplate(tpl *template.Template) error {
+	var missing []string
+	for _, name := range RequiredTemplates {
+		if tpl.Lookup(name) == nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingTemplate, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Parse templateString and use it for all outputs of this executor instead of
+// the default template, it must define all RequiredTemplates. Functions
+// added with AddTemplateFuncs must be added before calling this method.
+// Returns an error if the template can't be parsed or is incomplete, in which
+// case the executor template is left unchanged.
+func (e *JobExecutor) SetTemplateString(templateString string) error {
+	tpl, err := template.New("executor-output").Funcs(e.getTemplateFuncs()).Parse(templateString)
+	if err != nil {
+		return err
+	}
+	if err := validateTemplate(tpl); err != nil {
+		return err
+	}
+	e.template = tpl
+	return nil
+}
+
+// Add functions that can be used in the executor templates, they can also
+// override builtin ones. It must be called before SetTemplateString to be
+// used in a template string and not while jobs are running.
+// This method can be chained.
+func (e *JobExecutor) AddTemplateFuncs(funcs template.FuncMap) *JobExecutor {
+	if e.templateFuncs == nil {
+		e.templateFuncs = template.FuncMap{}
+	}
+	for name, fn := range funcs {
+		e.templateFuncs[name] = fn
+	}
+	if e.template != nil {
+		e.template.Funcs(funcs)
+	}
+	return e
+}
+
+// return builtin functions bound to this executor and added ones
+func (e *JobExecutor) getTemplateFuncs() template.FuncMap {
+	funcs := templateFuncs(e.useAnsi)
+	for name, fn := range e.templateFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func Test_formatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		1234567 * time.Nanosecond:             "1ms",
+		1234567890:                            "1.23s",
+		90*time.Second + 400*time.Millisecond: "1m30s",
+	}
+	for d, want := range tests {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func Test_colorString(t *testing.T) {
+	if got, _ := colorString(true, "bold red", "x"); got != "\033[1;31mx\033[0m" {
+		t.Errorf("unexpected colored string %q", got)
+	}
+	if got, _ := colorString(false, "bold red", "x"); got != "x" {
+		t.Errorf("styles should not be applied without ANSI, got %q", got)
+	}
+	if _, err := colorString(true, "blink", "x"); err == nil {
+		t.Error("unknown style should return an error")
+	}
+}
+
+// every builtin helper should be usable in executor templates
+func TestJobExecutor_templateFuncs(t *testing.T) {
+	e := NewExecutor().SetAnsiEnabled(true)
+	tpl := strings.Join([]string{
+		`{{define "startSummary"}}{{len .}} {{pluralize (len .) "job" "jobs"}}{{end}}`,
+		`{{define "jobStatusLine"}}{{stateName .}}|{{exitCode .}}|{{pad 6 .Name}}|{{truncate 3 .Name}}|{{color "green" "ok"}}|{{duration .Duration}}{{end}}`,
+		`{{define "jobStatusFull"}}{{.Res | tail 1 | indent 2}}{{end}}`,
+		`{{define "doneReport"}}{{trim "\nx\n"}}{{end}}`,
+		`{{define "startProgressReport"}}{{end}}`,
+		`{{define "progressReport"}}{{end}}`,
+	}, "")
+	if err := e.SetTemplateString(tpl); err != nil {
+		t.Fatal(err)
+	}
+	j := &job{Res: "line1\nline2\n", status: JobStateDone | JobStateSucceed, Duration: 2 * time.Millisecond, displayName: "build"}
+	tests := []struct {
+		name    string
+		subject interface{}
+		want    string
+	}{
+		{"startSummary", JobList{j, j}, "2 jobs"},
+		{"jobStatusLine", j, "succeed|-1|build |bu…|\033[32mok\033[0m|2ms"},
+		{"jobStatusFull", j, "  line2"},
+		{"doneReport", JobList{j}, "x"},
+	}
+	for _, tt := range tests {
+		if got := e.execTemplate(tt.name, tt.subject); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJobExecutor_SetTemplateString(t *testing.T) {
+	var errOut bytes.Buffer
+	e := NewExecutor().SetErrorOutput(&errOut)
+	err := e.SetTemplateString(`{{define "jobStatusLine"}}{{.Name}}{{end}}`)
+	if !errors.Is(err, ErrMissingTemplate) || !strings.Contains(err.Error(), "startSummary") {
+		t.Errorf("incomplete template should return ErrMissingTemplate, got %v", err)
+	}
+	if err := e.SetTemplateString(`{{define "jobStatusLine"}}{{unknownFunc .}}{{end}}`); err == nil {
+		t.Error("undefined function should return an error")
+	}
+	// executor template must be left unchanged
+	if got := e.execTemplate("jobStatusLine", &job{displayName: "a"}); got != "⏳ pending  a\n" {
+		t.Errorf("template should be unchanged after errors, got %q", got)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("unexpected error output %q", errOut.String())
+	}
+	// other executors are not affected
+	e.AddTemplateFuncs(template.FuncMap{"shout": strings.ToUpper})
+	tpl := strings.Replace(dfltTemplateString, `{{define "jobStatusLine"}}`, `{{define "dfltJobStatusLine"}}`, 1) +
+		`{{define "jobStatusLine"}}{{shout .Name}}{{end}}`
+	if err := e.SetTemplateString(tpl); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.execTemplate("jobStatusLine", &job{displayName: "a"}); got != "A" {
+		t.Errorf("added function should be used, got %q", got)
+	}
+	if got := NewExecutor().execTemplate("jobStatusLine", &job{displayName: "a"}); got != "⏳ pending  a\n" {
+		t.Errorf("other executors should keep the default template, got %q", got)
+	}
+}
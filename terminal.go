@@ -0,0 +1,98 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// syncWriter serialize writes to the underlying writer as outputs handlers may
+// be called concurrently
+type syncWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// default outputs shared by all executors
+var stdoutWriter = newSyncWriter(os.Stdout)
+var stderrWriter = newSyncWriter(os.Stderr)
+
+func newSyncWriter(w io.Writer) *syncWriter {
+	return &syncWriter{w: w}
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.w.Write(p)
+}
+
+// return the *os.File behind w if any
+func getWriterFile(w io.Writer) *os.File {
+	if sw, ok := w.(*syncWriter); ok {
+		w = sw.w
+	}
+	f, _ := w.(*os.File)
+	return f
+}
+
+// return the file descriptor of f without switching it to blocking mode
+// like f.Fd() does, so read deadlines still work on it
+func getFileFd(f *os.File) int {
+	fd := -1
+	if rawConn, err := f.SyscallConn(); err == nil {
+		rawConn.Control(func(rawFd uintptr) { fd = int(rawFd) })
+	}
+	return fd
+}
+
+// check if w is connected to a terminal, writers other than *os.File (or a
+// syncWriter wrapping one) are never considered as terminals
+func isTerminal(w io.Writer) bool {
+	f := getWriterFile(w)
+	return f != nil && term.IsTerminal(int(f.Fd()))
+}
+
+// return the size of the terminal connected to w, ok is false if w is not a terminal
+func getTerminalSize(w io.Writer) (width int, height int, ok bool) {
+	f := getWriterFile(w)
+	if f == nil {
+		return 0, 0, false
+	}
+	width, height, err := term.GetSize(int(f.Fd()))
+	return width, height, err == nil && width > 0 && height > 0
+}
+
+// truncate s to width runes, ANSI escape sequences are not supported
+func truncateString(s string, width int) string {
+	if width < 1 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// pad s with spaces on the right to width runes or truncate it if longer
+func padString(s string, width int) string {
+	s = truncateString(s, width)
+	if l := len([]rune(s)); l < width {
+		s += strings.Repeat(" ", width-l)
+	}
+	return s
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+// resize notifications are not supported on this platform, size changes will
+// be taken into account on next render
+func onTerminalResize(stop <-chan struct{}, fn func()) {}
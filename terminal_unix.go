@@ -0,0 +1,33 @@
+//go:build unix
+
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// call fn each time the terminal is resized until stop is closed
+func onTerminalResize(stop <-chan struct{}, fn func()) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGWINCH)
+	go func() {
+		defer signal.Stop(sigChan)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-sigChan:
+				fn()
+			}
+		}
+	}()
+}
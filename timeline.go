@@ -0,0 +1,187 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+)
+
+// TimelineEntry describes when and where a job was run
+type TimelineEntry struct {
+	Id        int
+	Name      string
+	State     string
+	StartTime time.Time
+	Duration  time.Duration
+	// index of the concurrency slot used by the job (0 to maxConcurrentJobs-1)
+	Slot int
+}
+
+// Return the timeline of the jobs that have been started, ordered by start time
+func (e *JobExecutor) GetTimeline() []TimelineEntry {
+	var timeline []TimelineEntry
+	for _, j := range e.jobs {
+		state := j.stateName()
+		j.mutex.RLock()
+		if !j.StartTime.IsZero() {
+			timeline = append(timeline, TimelineEntry{
+				Id:        j.id,
+				Name:      j.Name(),
+				State:     state,
+				StartTime: j.StartTime,
+				Duration:  j.Duration,
+				Slot:      j.slot,
+			})
+		}
+		j.mutex.RUnlock()
+	}
+	sort.SliceStable(timeline, func(a, b int) bool { return timeline[a].StartTime.Before(timeline[b].StartTime) })
+	return timeline
+}
+
+// Write the timeline of a finished run in chrome trace event JSON format,
+// each concurrency slot is rendered as a thread so you can see how busy they were.
+// Load the result in chrome://tracing or https://ui.perfetto.dev
+func (e *JobExecutor) WriteChromeTimeline(w io.Writer) error {
+	var events []chromeTraceEvent
+	maxSlot := -1
+	for _, entry := range e.GetTimeline() {
+		if entry.Slot > maxSlot {
+			maxSlot = entry.Slot
+		}
+		events = append(events, chromeTraceEvent{
+			Name:      entry.Name,
+			Category:  "job",
+			Phase:     "X",
+			Timestamp: entry.StartTime.UnixMicro(),
+			Duration:  entry.Duration.Microseconds(),
+			Pid:       1,
+			Tid:       entry.Slot,
+			Args:      map[string]interface{}{"id": entry.Id, "state": entry.State},
+		})
+	}
+	for slot := 0; slot <= maxSlot; slot++ {
+		events = append(events, chromeTraceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			Pid:   1,
+			Tid:   slot,
+			Args:  map[string]interface{}{"name": fmt.Sprintf("slot %d", slot)},
+		})
+	}
+	return writeChromeTrace(w, events)
+}
+
+type ganttBar struct {
+	TimelineEntry
+	Row   int
+	X     float64
+	Width float64
+	Color string
+}
+
+type ganttChart struct {
+	Title    string
+	Total    time.Duration
+	Jobs     []ganttBar
+	Slots    []ganttBar
+	SlotsLen int
+	// slot numbers to label rows of the slots chart
+	SlotIds []int
+}
+
+var ganttColors = map[string]string{
+	"succeed": "#4caf50",
+	"failed":  "#f44336",
+	"running": "#2196f3",
+}
+
+var ganttTemplate = template.Must(template.New("gantt").Funcs(template.FuncMap{
+	"mul": func(a int, b float64) float64 { return float64(a) * b },
+	"add": func(a, b float64) float64 { return a + b },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>
+body{font-family:sans-serif;background:#121212;color:#f0f0f0;margin:2em}
+svg{background:#1e1e1e;border-radius:4px}
+text{fill:#f0f0f0;font-size:12px}
+.label{text-anchor:end}
+.grid{stroke:#333}
+</style></head><body>
+<h1>{{.Title}}</h1>
+<p>total: {{.Total}}, {{len .Jobs}} jobs, {{.SlotsLen}} concurrency slots used</p>
+<h2>Jobs</h2>
+<svg width="1000" height="{{add (mul (len .Jobs) 22) 30}}">
+{{- range .Jobs}}
+<text class="label" x="245" y="{{add (mul .Row 22) 35}}">{{.Name}}</text>
+<rect x="{{add .X 250}}" y="{{add (mul .Row 22) 22}}" width="{{.Width}}" height="18" fill="{{.Color}}"><title>{{.Name}} ({{.State}}) slot {{.Slot}}: {{.Duration}}</title></rect>
+{{- end}}
+</svg>
+<h2>Concurrency slots</h2>
+<svg width="1000" height="{{add (mul .SlotsLen 22) 30}}">
+{{- range .SlotIds}}
+<text class="label" x="245" y="{{add (mul . 22) 35}}">slot {{.}}</text>
+{{- end}}
+{{- range .Slots}}
+<rect x="{{add .X 250}}" y="{{add (mul .Row 22) 22}}" width="{{.Width}}" height="18" fill="{{.Color}}" stroke="#121212"><title>{{.Name}} ({{.State}}): {{.Duration}}</title></rect>
+{{- end}}
+</svg>
+</body></html>
+`))
+
+// Write a self-contained HTML page with SVG Gantt charts of a finished run:
+// one with a row per job and one with a row per concurrency slot.
+func (e *JobExecutor) WriteGanttHTML(w io.Writer) error {
+	timeline := e.GetTimeline()
+	chart := ganttChart{Title: "jobExecutor timeline"}
+	var start, end time.Time
+	for _, entry := range timeline {
+		if start.IsZero() || entry.StartTime.Before(start) {
+			start = entry.StartTime
+		}
+		if entryEnd := entry.StartTime.Add(entry.Duration); entryEnd.After(end) {
+			end = entryEnd
+		}
+	}
+	chart.Total = end.Sub(start)
+	// chart area is 740px wide
+	scale := 740.0
+	if chart.Total > 0 {
+		scale = 740.0 / float64(chart.Total)
+	}
+	for i, entry := range timeline {
+		color, ok := ganttColors[entry.State]
+		if !ok {
+			color = "#9e9e9e"
+		}
+		bar := ganttBar{
+			TimelineEntry: entry,
+			Row:           i,
+			X:             float64(entry.StartTime.Sub(start)) * scale,
+			Width:         float64(entry.Duration) * scale,
+			Color:         color,
+		}
+		if bar.Width < 1 {
+			bar.Width = 1
+		}
+		chart.Jobs = append(chart.Jobs, bar)
+		bar.Row = entry.Slot
+		chart.Slots = append(chart.Slots, bar)
+		if entry.Slot+1 > chart.SlotsLen {
+			chart.SlotsLen = entry.Slot + 1
+		}
+	}
+	for slot := 0; slot < chart.SlotsLen; slot++ {
+		chart.SlotIds = append(chart.SlotIds, slot)
+	}
+	return ganttTemplate.Execute(w, chart)
+}
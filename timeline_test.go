@@ -0,0 +1,57 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobExecutor_GetTimeline(t *testing.T) {
+	SetMaxConcurrentJobs(2)
+	defer SetMaxConcurrentJobs(0)
+	sleepFn := func() (string, error) { time.Sleep(20 * time.Millisecond); return "", nil }
+	e := NewExecutor().AddJobFns(sleepFn, sleepFn, sleepFn, sleepFn)
+	e.Execute()
+
+	timeline := e.GetTimeline()
+	if len(timeline) != 4 {
+		t.Fatalf("expected 4 timeline entries got %d", len(timeline))
+	}
+	for i, entry := range timeline {
+		if entry.Slot < 0 || entry.Slot > 1 {
+			t.Errorf("job %d used slot %d while only 2 concurrent jobs are allowed", entry.Id, entry.Slot)
+		}
+		if i > 0 && entry.StartTime.Before(timeline[i-1].StartTime) {
+			t.Errorf("timeline is not ordered by start time")
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := e.WriteChromeTimeline(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var trace chromeTrace
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("invalid chrome trace: %v", err)
+	}
+	if len(trace.TraceEvents) != 6 { // 4 jobs + 2 slots names
+		t.Errorf("expected 6 trace events got %d", len(trace.TraceEvents))
+	}
+
+	buf.Reset()
+	if err := e.WriteGanttHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "<rect") != 8 { // 4 jobs in 2 charts
+		t.Errorf("expected 8 bars in gantt chart got %d", strings.Count(buf.String(), "<rect"))
+	}
+}
@@ -0,0 +1,391 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Span attributes keys set on job spans
+const (
+	SpanAttrJobId       = "job.id"
+	SpanAttrJobName     = "job.name"
+	SpanAttrJobKind     = "job.kind"
+	SpanAttrJobExitCode = "job.exit_code"
+	SpanAttrJobAttempt  = "job.attempt"
+	SpanAttrJobOutcome  = "job.outcome"
+)
+
+type SpanLink struct {
+	TraceID string
+	SpanID  string
+}
+
+// Span represents a timed operation: the whole run or a single job.
+// TraceID and SpanID are hex encoded as in the OpenTelemetry specification.
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	StartTime    time.Time
+	EndTime      time.Time
+	// values are string, int, bool or float64
+	Attributes map[string]interface{}
+	// links to the spans of the jobs this one depends on
+	Links []SpanLink
+	// error message when the span represents a failure
+	Error string
+}
+
+// SpanExporter receives all the spans of a run once all jobs are done
+type SpanExporter interface {
+	ExportSpans(spans []Span) error
+}
+
+func randomHexId(bytesLen int) string {
+	b := make([]byte, bytesLen)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// return a label describing how a job ended
+func jobOutcome(j *job) string {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	switch {
+	case errors.Is(j.Err, ErrRequiredJobFailed):
+		return "skipped"
+	case errors.Is(j.Err, ErrJobCanceled):
+		return "canceled"
+	case j.status&JobStateSucceed != 0:
+		return "succeed"
+	case j.status&JobStateFailed != 0:
+		return "failed"
+	}
+	return "pending"
+}
+
+func jobKind(j *job) string {
+	if j.Cmd != nil {
+		return "cmd"
+	}
+	return "fn"
+}
+
+// Record a root span for the whole run and a child span for each job, then
+// send them to the exporter when all jobs are done. Spans of jobs are linked
+// to the spans of their dependencies. Export errors are written to the executor error output.
+func (e *JobExecutor) WithTracing(exporter SpanExporter) *JobExecutor {
+	var mutex sync.Mutex
+	var root Span
+	var spans map[int]*Span
+	var order []int
+	e.onJobsStart(func(jobs JobList) {
+		mutex.Lock()
+		root = Span{
+			TraceID:    randomHexId(16),
+			SpanID:     randomHexId(8),
+			Name:       "jobExecutor",
+			StartTime:  time.Now(),
+			Attributes: map[string]interface{}{"jobs.count": len(jobs)},
+		}
+		spans = make(map[int]*Span, len(jobs))
+		order = nil
+		mutex.Unlock()
+	})
+	// must be called with the mutex locked
+	startSpan := func(j *job) *Span {
+		span := &Span{
+			TraceID:      root.TraceID,
+			SpanID:       randomHexId(8),
+			ParentSpanID: root.SpanID,
+			Name:         j.Name(),
+			StartTime:    time.Now(),
+			Attributes: map[string]interface{}{
+				SpanAttrJobId:   j.id,
+				SpanAttrJobName: j.Name(),
+				SpanAttrJobKind: jobKind(j),
+			},
+		}
+		for _, dep := range j.DependsOn {
+			if depSpan, ok := spans[dep.id]; ok {
+				span.Links = append(span.Links, SpanLink{TraceID: depSpan.TraceID, SpanID: depSpan.SpanID})
+			}
+		}
+		spans[j.id] = span
+		order = append(order, j.id)
+		return span
+	}
+	e.onJobStart(func(jobs JobList, jobId int) {
+		mutex.Lock()
+		startSpan(jobs[jobId])
+		mutex.Unlock()
+	})
+	e.onJobDone(func(jobs JobList, jobId int) {
+		j := jobs[jobId]
+		outcome := jobOutcome(j)
+		exitCode := j.exitCode()
+		j.mutex.RLock()
+		attempt := j.attempts
+		err := j.Err
+		j.mutex.RUnlock()
+		mutex.Lock()
+		span, ok := spans[jobId]
+		if !ok { // skipped jobs are never started
+			span = startSpan(j)
+		}
+		span.EndTime = time.Now()
+		span.Attributes[SpanAttrJobOutcome] = outcome
+		span.Attributes[SpanAttrJobAttempt] = attempt
+		if exitCode >= 0 {
+			span.Attributes[SpanAttrJobExitCode] = exitCode
+		}
+		if err != nil {
+			span.Error = err.Error()
+		}
+		mutex.Unlock()
+	})
+	e.onJobsDone(func(jobs JobList) {
+		mutex.Lock()
+		root.EndTime = time.Now()
+		res := make([]Span, 0, len(order)+1)
+		res = append(res, root)
+		for _, jobId := range order {
+			res = append(res, *spans[jobId])
+		}
+		mutex.Unlock()
+		if err := exporter.ExportSpans(res); err != nil {
+			fmt.Fprintln(e.getErrOutput(), "can't export spans:", err)
+		}
+	})
+	return e
+}
+
+// ************************** Chrome trace exporter **************************//
+
+// event of the chrome trace event format
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type chromeTraceEvent struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat,omitempty"`
+	Phase     string                 `json:"ph"`
+	Timestamp int64                  `json:"ts"`
+	Duration  int64                  `json:"dur,omitempty"`
+	Pid       int                    `json:"pid"`
+	Tid       int                    `json:"tid"`
+	Id        string                 `json:"id,omitempty"`
+	BindPoint string                 `json:"bp,omitempty"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+type chromeTrace struct {
+	TraceEvents     []chromeTraceEvent `json:"traceEvents"`
+	DisplayTimeUnit string             `json:"displayTimeUnit"`
+}
+
+func writeChromeTrace(w io.Writer, events []chromeTraceEvent) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(chromeTrace{TraceEvents: events, DisplayTimeUnit: "ms"})
+}
+
+type chromeTraceExporter struct {
+	w io.Writer
+}
+
+// Return a SpanExporter writing spans in chrome trace event JSON format to w,
+// which can be loaded in chrome://tracing or https://ui.perfetto.dev.
+// Links are rendered as flow arrows between jobs.
+func NewChromeTraceExporter(w io.Writer) SpanExporter {
+	return &chromeTraceExporter{w: w}
+}
+
+func (c *chromeTraceExporter) ExportSpans(spans []Span) error {
+	var events []chromeTraceEvent
+	tids := make(map[string]int, len(spans))
+	ends := make(map[string]time.Time, len(spans))
+	for i, span := range spans {
+		tids[span.SpanID] = i
+		ends[span.SpanID] = span.EndTime
+		args := make(map[string]interface{}, len(span.Attributes)+1)
+		for k, v := range span.Attributes {
+			args[k] = v
+		}
+		if span.Error != "" {
+			args["error"] = span.Error
+		}
+		events = append(events, chromeTraceEvent{
+			Name:      span.Name,
+			Category:  "jobExecutor",
+			Phase:     "X",
+			Timestamp: span.StartTime.UnixMicro(),
+			Duration:  span.EndTime.Sub(span.StartTime).Microseconds(),
+			Pid:       1,
+			Tid:       i,
+			Args:      args,
+		})
+	}
+	for _, span := range spans {
+		for _, link := range span.Links {
+			id := link.SpanID + "-" + span.SpanID
+			events = append(events,
+				chromeTraceEvent{Name: "dependency", Category: "jobExecutor", Phase: "s", Id: id, Pid: 1, Tid: tids[link.SpanID], Timestamp: ends[link.SpanID].UnixMicro() - 1},
+				chromeTraceEvent{Name: "dependency", Category: "jobExecutor", Phase: "f", BindPoint: "e", Id: id, Pid: 1, Tid: tids[span.SpanID], Timestamp: span.StartTime.UnixMicro()},
+			)
+		}
+	}
+	return writeChromeTrace(c.w, events)
+}
+
+// ************************** OTLP exporter **************************//
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLink struct {
+	TraceId string `json:"traceId"`
+	SpanId  string `json:"spanId"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Links             []otlpLink     `json:"links,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func toOtlpKeyValue(key string, value interface{}) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	switch v := value.(type) {
+	case int:
+		s := strconv.Itoa(v)
+		kv.Value.IntValue = &s
+	case bool:
+		kv.Value.BoolValue = &v
+	case float64:
+		kv.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
+
+type otlpExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+}
+
+// Return a SpanExporter sending spans to an OpenTelemetry collector using
+// OTLP over HTTP with JSON encoding. endpoint is the full traces url,
+// typically "http://localhost:4318/v1/traces"
+func NewOTLPExporter(endpoint string, serviceName string) SpanExporter {
+	return &otlpExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (o *otlpExporter) ExportSpans(spans []Span) error {
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: "github.com/software-t-rex/go-jobExecutor"}}
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceId:           span.TraceID,
+			SpanId:            span.SpanID,
+			ParentSpanId:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Status:            otlpStatus{Code: 1}, // STATUS_CODE_OK
+		}
+		for k, v := range span.Attributes {
+			s.Attributes = append(s.Attributes, toOtlpKeyValue(k, v))
+		}
+		for _, link := range span.Links {
+			s.Links = append(s.Links, otlpLink{TraceId: link.TraceID, SpanId: link.SpanID})
+		}
+		if span.Error != "" {
+			s.Status = otlpStatus{Code: 2, Message: span.Error} // STATUS_CODE_ERROR
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, s)
+	}
+	req := otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{toOtlpKeyValue("service.name", o.serviceName)}},
+		ScopeSpans: []otlpScopeSpans{scopeSpans},
+	}}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Post(o.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("otlp collector responded with status %s", resp.Status)
+	}
+	return nil
+}
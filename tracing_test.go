@@ -0,0 +1,86 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+)
+
+type recordingExporter struct {
+	spans []Span
+}
+
+func (r *recordingExporter) ExportSpans(spans []Span) error {
+	r.spans = spans
+	return nil
+}
+
+func TestJobExecutor_WithTracing(t *testing.T) {
+	exporter := &recordingExporter{}
+	e := NewExecutor().WithTracing(exporter)
+	jobs := e.AddJobs(
+		NamedJob{"cmd", exec.Command("bash", "-c", "exit 3")},
+		NamedJob{"fn", TestRunnableSuccessFn},
+	)
+	e.AddJobDependency(jobs[0], jobs[1])
+	skipped := e.AddJob(NamedJob{"skipped", TestRunnableSuccessFn})
+	e.AddJobDependency(skipped, jobs[0])
+	e.DagExecute()
+
+	if len(exporter.spans) != 4 {
+		t.Fatalf("expected 4 spans got %d", len(exporter.spans))
+	}
+	if skippedSpan := exporter.spans[3]; skippedSpan.Attributes[SpanAttrJobOutcome] != "skipped" || skippedSpan.Attributes[SpanAttrJobAttempt] != 0 {
+		t.Errorf("unexpected skipped span attributes %v", skippedSpan.Attributes)
+	}
+	root := exporter.spans[0]
+	fnSpan, cmdSpan := exporter.spans[1], exporter.spans[2]
+	if fnSpan.ParentSpanID != root.SpanID || cmdSpan.TraceID != root.TraceID {
+		t.Fatal("job spans should be children of the root span")
+	}
+	if fnSpan.Attributes[SpanAttrJobKind] != "fn" || fnSpan.Attributes[SpanAttrJobOutcome] != "succeed" || fnSpan.Attributes[SpanAttrJobAttempt] != 1 {
+		t.Errorf("unexpected fn span attributes %v", fnSpan.Attributes)
+	}
+	if cmdSpan.Attributes[SpanAttrJobExitCode] != 3 || cmdSpan.Attributes[SpanAttrJobOutcome] != "failed" || cmdSpan.Error == "" {
+		t.Errorf("unexpected cmd span %+v", cmdSpan)
+	}
+	if len(cmdSpan.Links) != 1 || cmdSpan.Links[0].SpanID != fnSpan.SpanID {
+		t.Errorf("cmd span should be linked to its dependency span")
+	}
+
+	// chrome exporter
+	var buf bytes.Buffer
+	if err := NewChromeTraceExporter(&buf).ExportSpans(exporter.spans); err != nil {
+		t.Fatal(err)
+	}
+	var trace chromeTrace
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("invalid chrome trace: %v", err)
+	}
+	if len(trace.TraceEvents) != 8 { // 4 spans + 2 flow events per link
+		t.Errorf("expected 8 trace events got %d", len(trace.TraceEvents))
+	}
+
+	// otlp exporter
+	var received otlpTracesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+	if err := NewOTLPExporter(server.URL, "test").ExportSpans(exporter.spans); err != nil {
+		t.Fatal(err)
+	}
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans[0].Spans) != 4 {
+		t.Fatalf("unexpected otlp request %+v", received)
+	}
+}
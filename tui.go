@@ -0,0 +1,333 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const tuiMaxTailLines = 1000
+const tuiRefreshInterval = 100 * time.Millisecond
+
+var tuiSpinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// tuiDashboard renders a full screen view of jobs with the output of the
+// selected one. Keyboard input is read from the controlling terminal.
+type tuiDashboard struct {
+	mutex     sync.Mutex
+	jobs      JobList
+	out       io.Writer
+	tty       *os.File
+	ttyState  *term.State
+	selected  int
+	logOffset int // number of lines scrolled up from the end of the log
+	tails     map[int][]string
+	frame     int
+	startTime time.Time
+	stop      chan struct{}
+	stopOnce  sync.Once
+	rendered  chan struct{}
+	keysRead  chan struct{}
+}
+
+func newTuiDashboard(jobs JobList, out io.Writer) *tuiDashboard {
+	return &tuiDashboard{
+		jobs:      jobs,
+		out:       out,
+		tails:     make(map[int][]string),
+		startTime: time.Now(),
+		stop:      make(chan struct{}),
+		rendered:  make(chan struct{}),
+		keysRead:  make(chan struct{}),
+	}
+}
+
+// switch to the alternate screen and start rendering and reading keys
+func (d *tuiDashboard) start() {
+	fmt.Fprint(d.out, "\033[?1049h\033[?25l") // alternate screen, hide cursor
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		if state, err := term.MakeRaw(getFileFd(tty)); err == nil {
+			d.tty = tty
+			d.ttyState = state
+			go d.readKeys()
+		} else {
+			tty.Close()
+		}
+	}
+	go func() {
+		defer close(d.rendered)
+		ticker := time.NewTicker(tuiRefreshInterval)
+		defer ticker.Stop()
+		for {
+			d.render()
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// restore the terminal as it was before start, it can be called more than once
+func (d *tuiDashboard) close() {
+	d.stopOnce.Do(d.doClose)
+}
+
+func (d *tuiDashboard) doClose() {
+	close(d.stop)
+	<-d.rendered
+	if d.tty != nil {
+		// unblock the pending read if possible, if not the reader will stop
+		// after next key press
+		if d.tty.SetReadDeadline(time.Now()) == nil {
+			select {
+			case <-d.keysRead:
+			case <-time.After(tuiRefreshInterval):
+			}
+		}
+		term.Restore(getFileFd(d.tty), d.ttyState)
+		d.tty.Close()
+	}
+	fmt.Fprint(d.out, "\033[?25h\033[?1049l") // show cursor, leave alternate screen
+}
+
+func (d *tuiDashboard) readKeys() {
+	defer close(d.keysRead)
+	buf := make([]byte, 16)
+	for {
+		n, err := d.tty.Read(buf)
+		if err != nil {
+			return
+		}
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+		if d.handleKey(string(buf[:n])) {
+			// restore the terminal without waiting for canceled jobs to end
+			go d.close()
+			return
+		}
+		d.render()
+	}
+}
+
+// check the dashboard was closed
+func (d *tuiDashboard) stopped() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *tuiDashboard) appendLine(jobId int, line string) {
+	d.mutex.Lock()
+	tail := append(d.tails[jobId], line)
+	if len(tail) > tuiMaxTailLines {
+		tail = tail[len(tail)-tuiMaxTailLines:]
+	}
+	d.tails[jobId] = tail
+	d.mutex.Unlock()
+}
+
+// handle a key press, return true if the dashboard should be closed
+func (d *tuiDashboard) handleKey(key string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	switch key {
+	case "\033[A", "k": // up
+		if d.selected > 0 {
+			d.selected--
+			d.logOffset = 0
+		}
+	case "\033[B", "j": // down
+		if d.selected < len(d.jobs)-1 {
+			d.selected++
+			d.logOffset = 0
+		}
+	case "\033[5~", "b": // page up
+		d.logOffset += 10
+		if maxOffset := len(d.tails[d.selected]) - 1; d.logOffset > maxOffset {
+			d.logOffset = maxOffset
+		}
+		if d.logOffset < 0 {
+			d.logOffset = 0
+		}
+	case "\033[6~", " ": // page down
+		d.logOffset -= 10
+		if d.logOffset < 0 {
+			d.logOffset = 0
+		}
+	case "c":
+		if d.selected < len(d.jobs) {
+			d.jobs[d.selected].cancel()
+		}
+	case "q", "\x03": // ctrl+c won't send SIGINT in raw mode
+		for _, j := range d.jobs {
+			j.cancel()
+		}
+		return true
+	}
+	return false
+}
+
+func tuiJobRow(j *job, selected bool, frame int, width int) string {
+	marker := "  "
+	if selected {
+		marker = "› "
+	}
+	var symbol, color, elapsed string
+	j.mutex.RLock()
+	startTime, duration := j.StartTime, j.Duration
+	j.mutex.RUnlock()
+	switch j.stateName() {
+	case "running":
+		symbol, color = tuiSpinner[frame%len(tuiSpinner)], "36"
+		elapsed = time.Since(startTime).Truncate(100 * time.Millisecond).String()
+	case "succeed":
+		symbol, color = "✔", "32"
+		elapsed = duration.Truncate(time.Millisecond).String()
+	case "failed":
+		symbol, color = "✖", "31"
+		elapsed = duration.Truncate(time.Millisecond).String()
+	default:
+		symbol, color = "·", "2"
+	}
+	nameWidth := width - 4 - len(elapsed) - 1
+	row := marker + "\033[" + color + "m" + symbol + "\033[0m " + padString(j.Name(), nameWidth) + " " + elapsed
+	if selected {
+		row = "\033[1m" + row + "\033[0m"
+	}
+	return row
+}
+
+// return the full frame for the given terminal size
+func (d *tuiDashboard) frameString(width int, height int) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.frame++
+	done := 0
+	for _, j := range d.jobs {
+		if j.IsState(JobStateDone) {
+			done++
+		}
+	}
+	lines := []string{
+		truncateString(fmt.Sprintf(" jobExecutor %s %d/%d %s", progressBarString(done, len(d.jobs), 20), done, len(d.jobs), time.Since(d.startTime).Truncate(time.Second)), width),
+	}
+	// split remaining space between job list and log
+	available := height - 3 // header, log title and footer
+	listHeight := len(d.jobs)
+	if maxHeight := available / 2; listHeight > maxHeight {
+		listHeight = maxHeight
+	}
+	if listHeight < 1 {
+		listHeight = 1
+	}
+	first := 0
+	if d.selected >= listHeight {
+		first = d.selected - listHeight + 1
+	}
+	for i := first; i < first+listHeight && i < len(d.jobs); i++ {
+		lines = append(lines, tuiJobRow(d.jobs[i], i == d.selected, d.frame, width))
+	}
+	title := "── "
+	if d.selected < len(d.jobs) {
+		title += d.jobs[d.selected].Name() + " "
+	}
+	if d.logOffset > 0 {
+		title += fmt.Sprintf("(-%d) ", d.logOffset)
+	}
+	lines = append(lines, "\033[2m"+truncateString(title+strings.Repeat("─", width), width)+"\033[0m")
+	logHeight := height - len(lines) - 1
+	tail := d.tails[d.selected]
+	end := len(tail) - d.logOffset
+	if end < 0 {
+		end = 0
+	}
+	start := end - logHeight
+	if start < 0 {
+		start = 0
+	}
+	for _, line := range tail[start:end] {
+		lines = append(lines, truncateString(line, width))
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "\033[2m"+truncateString("↑/↓ select  PgUp/PgDn scroll output  c cancel job  q cancel all", width)+"\033[0m")
+	return "\033[H" + strings.Join(lines, "\033[K\r\n") + "\033[K\033[J"
+}
+
+func (d *tuiDashboard) render() {
+	width, height, ok := getTerminalSize(d.out)
+	if !ok {
+		width, height = 80, 24
+	}
+	fmt.Fprint(d.out, d.frameString(width, height))
+}
+
+// Display a full screen dashboard while jobs are running: one row per job
+// with its state, elapsed time and a spinner, the output of the selected job
+// and the overall progress. Use up/down arrows to select a job, PgUp/PgDn to
+// scroll its output, c to cancel it and q (or ctrl+c) to cancel all jobs and
+// leave the dashboard.
+// When the output is not a terminal it prints a status line each time a job
+// starts or ends instead.
+func (e *JobExecutor) WithTUIOutput() *JobExecutor {
+	var dashboard *tuiDashboard
+	var mutex sync.Mutex
+	getDashboard := func() *tuiDashboard {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return dashboard
+	}
+	printLine := func(jobs JobList, jobId int) {
+		if d := getDashboard(); d == nil || d.stopped() {
+			fmt.Fprint(e.getOutput(), e.execTemplate("jobStatusLine", jobs[jobId]))
+		}
+	}
+	e.onJobsStart(func(jobs JobList) {
+		if len(jobs) == 0 || !e.useAnsi() || !isTerminal(e.getOutput()) {
+			return
+		}
+		mutex.Lock()
+		dashboard = newTuiDashboard(jobs, e.getOutput())
+		dashboard.start()
+		mutex.Unlock()
+	})
+	e.onJobStart(printLine)
+	e.onJobDone(printLine)
+	e.onJobOutput(func(jobs JobList, jobId int, line string) {
+		if d := getDashboard(); d != nil {
+			d.appendLine(jobId, line)
+		}
+	})
+	e.onJobsDone(func(jobs JobList) {
+		mutex.Lock()
+		d := dashboard
+		dashboard = nil
+		mutex.Unlock()
+		if d != nil {
+			d.close()
+			fmt.Fprint(e.getOutput(), e.execTemplate("progressReport", jobs))
+		}
+	})
+	return e
+}
@@ -0,0 +1,73 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTuiDashboard(t *testing.T) {
+	jobs := JobList{
+		&job{id: 0, displayName: "first", Fn: TestRunnableSuccessFn},
+		&job{id: 1, displayName: "second", Fn: TestRunnableSuccessFn},
+	}
+	d := newTuiDashboard(jobs, &bytes.Buffer{})
+	d.appendLine(0, "output of first")
+	d.appendLine(1, "output of second")
+
+	frame := d.frameString(40, 10)
+	if lines := strings.Split(frame, "\r\n"); len(lines) != 10 {
+		t.Fatalf("frame should fill the 10 lines of the terminal got %d", len(lines))
+	}
+	if !strings.Contains(frame, "first") || !strings.Contains(frame, "second") || !strings.Contains(frame, "output of first") {
+		t.Fatalf("frame should contain jobs and output of selected job: %q", frame)
+	}
+	d.handleKey("\033[B")
+	frame = d.frameString(40, 10)
+	if !strings.Contains(frame, "output of second") || strings.Contains(frame, "output of first") {
+		t.Fatalf("frame should contain output of the newly selected job: %q", frame)
+	}
+	if d.handleKey("c") || !jobs[1].canceled || jobs[0].canceled {
+		t.Fatal("c key should cancel the selected job only")
+	}
+	if !d.handleKey("q") || !jobs[0].canceled {
+		t.Fatal("q key should cancel all jobs and close the dashboard")
+	}
+}
+
+func TestTuiDashboard_noJobs(t *testing.T) {
+	d := newTuiDashboard(JobList{}, &bytes.Buffer{})
+	d.handleKey("c")
+	d.handleKey("b")
+	if frame := d.frameString(40, 10); len(strings.Split(frame, "\r\n")) != 10 {
+		t.Fatalf("frame should fill the terminal even without jobs: %q", frame)
+	}
+}
+
+func TestTuiDashboard_close(t *testing.T) {
+	var out bytes.Buffer
+	d := newTuiDashboard(JobList{}, &out)
+	close(d.rendered) // not started to leave the terminal alone
+	d.close()
+	d.close()
+	if !d.stopped() || !strings.HasSuffix(out.String(), "\033[?25h\033[?1049l") {
+		t.Fatalf("dashboard should be closed once and restore the screen: %q", out.String())
+	}
+}
+
+func TestJobExecutor_WithTUIOutput(t *testing.T) {
+	var out bytes.Buffer
+	NewExecutor().SetOutput(&out).WithTUIOutput().
+		AddJobFns(TestRunnableSuccessFn).
+		Execute()
+	if strings.Contains(out.String(), "\033") || !strings.Contains(out.String(), "Success") {
+		t.Fatalf("should print plain status lines when output is not a terminal: %q", out.String())
+	}
+}
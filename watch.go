@@ -0,0 +1,170 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// map of file path to their last known stamp
+type pathsSnapshot map[string]fileStamp
+
+func (s pathsSnapshot) equals(other pathsSnapshot) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for path, stamp := range s {
+		if otherStamp, ok := other[path]; !ok || !stamp.modTime.Equal(otherStamp.modTime) || stamp.size != otherStamp.size {
+			return false
+		}
+	}
+	return true
+}
+
+// return a snapshot of all files matching given paths
+// directories are walked recursively and glob patterns are expanded
+func takePathsSnapshot(paths []string) pathsSnapshot {
+	snapshot := make(pathsSnapshot)
+	for _, pattern := range paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			matches = []string{pattern}
+		}
+		for _, match := range matches {
+			filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return nil
+				}
+				if info, err := d.Info(); err == nil {
+					snapshot[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+				}
+				return nil
+			})
+		}
+	}
+	return snapshot
+}
+
+// return ids of given jobs and all the jobs that depend on them directly or not
+func getJobsWithDependents(jobs JobList, ids []int) []int {
+	dependents := make(map[int][]int, len(jobs))
+	for _, job := range jobs {
+		for _, dep := range job.DependsOn {
+			dependents[dep.id] = append(dependents[dep.id], job.id)
+		}
+	}
+	seen := make(map[int]bool, len(jobs))
+	queue := append([]int{}, ids...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, dependents[id]...)
+	}
+	res := make([]int, 0, len(seen))
+	for id := range seen {
+		res = append(res, id)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// Execute jobs like DagExecute then watch for changes in paths registered with
+// AddJobWatchPaths. Each time a change is detected the affected jobs and all
+// jobs depending on them are canceled if running and executed again.
+// Event handlers are called for each run so With*Output methods will print
+// their reports again.
+// paths are polled every interval, and it blocks until stop is closed.
+// It returns ErrCyclicDependencyDetected if jobs can't be run in a DAG.
+func (e *JobExecutor) DagWatch(stop <-chan struct{}, interval time.Duration) error {
+	if !e.IsAcyclic() {
+		return ErrCyclicDependencyDetected
+	}
+	snapshots := make(map[int]pathsSnapshot, e.Len())
+	for _, job := range e.jobs {
+		if len(job.watchPaths) > 0 {
+			snapshots[job.id] = takePathsSnapshot(job.watchPaths)
+		}
+	}
+	runDone := make(chan struct{})
+	running := false
+	startRun := func(ids []int) {
+		running = true
+		for _, id := range ids {
+			e.jobs[id].reset()
+		}
+		go func() {
+			dagExecuteSubset(e.jobs, ids, *e.opts)
+			runDone <- struct{}{}
+		}()
+	}
+	allIds := make([]int, e.Len())
+	for i := range allIds {
+		allIds[i] = i
+	}
+	startRun(allIds)
+
+	pending := make(map[int]bool)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			if running {
+				for _, job := range e.jobs {
+					job.cancel()
+				}
+				<-runDone
+			}
+			return nil
+		case <-runDone:
+			running = false
+			if len(pending) > 0 {
+				ids := make([]int, 0, len(pending))
+				for id := range pending {
+					ids = append(ids, id)
+				}
+				pending = make(map[int]bool)
+				startRun(getJobsWithDependents(e.jobs, ids))
+			}
+		case <-ticker.C:
+			var changed []int
+			for id, snapshot := range snapshots {
+				newSnapshot := takePathsSnapshot(e.jobs[id].watchPaths)
+				if !snapshot.equals(newSnapshot) {
+					snapshots[id] = newSnapshot
+					changed = append(changed, id)
+				}
+			}
+			if len(changed) == 0 {
+				continue
+			}
+			affected := getJobsWithDependents(e.jobs, changed)
+			if !running {
+				startRun(affected)
+				continue
+			}
+			// cancel affected jobs and run them again once current run is over
+			for _, id := range affected {
+				e.jobs[id].cancel()
+				pending[id] = true
+			}
+		}
+	}
+}
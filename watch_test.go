@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 Jonathan Gotti <jgotti at jgotti dot org>
+SPDX-FileType: SOURCE
+SPDX-License-Identifier: MIT
+SPDX-FileCopyrightText: 2023 Jonathan Gotti <jgotti@jgotti.org>
+*/
+
+package jobExecutor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout while waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func Test_getJobsWithDependents(t *testing.T) {
+	e := NewExecutor()
+	jobs := e.AddJobs(TestRunnableSuccessFn, TestRunnableSuccessFn, TestRunnableSuccessFn, TestRunnableSuccessFn)
+	e.AddJobDependency(jobs[1], jobs[0]).
+		AddJobDependency(jobs[2], jobs[1])
+	got := getJobsWithDependents(e.jobs, []int{1})
+	if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("getJobsWithDependents() = %v, want %v", got, want)
+	}
+	got = getJobsWithDependents(e.jobs, []int{0, 3})
+	if want := []int{0, 1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("getJobsWithDependents() = %v, want %v", got, want)
+	}
+}
+
+func TestJobExecutor_DagWatch(t *testing.T) {
+	dir := t.TempDir()
+	watched := filepath.Join(dir, "watched.txt")
+	if err := os.WriteFile(watched, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var runs [3]atomic.Int32
+	e := NewExecutor()
+	jobs := e.AddJobs(
+		func() (string, error) { runs[0].Add(1); return "", nil },
+		func() (string, error) { runs[1].Add(1); return "", nil },
+		func() (string, error) { runs[2].Add(1); return "", nil },
+	)
+	e.AddJobDependency(jobs[1], jobs[0]).AddJobWatchPaths(jobs[0], dir)
+
+	stop := make(chan struct{})
+	watchErr := make(chan error)
+	go func() { watchErr <- e.DagWatch(stop, 5*time.Millisecond) }()
+	waitFor(t, func() bool { return runs[0].Load() == 1 && runs[1].Load() == 1 && runs[2].Load() == 1 })
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(watched, later, later); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return runs[0].Load() == 2 && runs[1].Load() == 2 })
+	close(stop)
+	if err := <-watchErr; err != nil {
+		t.Fatalf("DagWatch() returned unexpected error %v", err)
+	}
+	if runs[2].Load() != 1 {
+		t.Fatalf("unaffected job was run %d times instead of 1", runs[2].Load())
+	}
+
+	// cyclic dependencies
+	e2 := NewExecutor()
+	jobs2 := e2.AddJobs(TestRunnableSuccessFn, TestRunnableSuccessFn)
+	e2.AddJobDependency(jobs2[0], jobs2[1]).AddJobDependency(jobs2[1], jobs2[0])
+	if err := e2.DagWatch(make(chan struct{}), time.Millisecond); err != ErrCyclicDependencyDetected {
+		t.Fatalf("expected ErrCyclicDependencyDetected, got %v", err)
+	}
+}